@@ -0,0 +1,22 @@
+/*
+ * Package alpmdb parses pacman sync databases (repo.db, repo.files),
+ * the local installed packages database, pacman.conf,
+ * and generates the sqlite database pacman.db
+ *
+ *	f, _ := os.Open("core.db")
+ *	pkgs, err := alpmdb.ExtractTarGz(f, nil, "core", nil)
+ *	err = alpmdb.GenSqlite("pacman.db", pkgs, nil, nil)
+ */
+package alpmdb
+
+import (
+	"fmt"
+	"io"
+)
+
+// progress messages, alpm-db command use os.Stdout
+var Output io.Writer = io.Discard
+
+func logln(a ...interface{}) {
+	fmt.Fprintln(Output, a...)
+}
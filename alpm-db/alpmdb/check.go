@@ -0,0 +1,88 @@
+package alpmdb
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+)
+
+const (
+	CHECK_MISSING = "missing" // no package or provide with this name
+	CHECK_VERSION = "version" // names exist, no version satisfies the constraint
+)
+
+/*
+ * a dependency not satisfied by packages of synced repos
+ * AVAILABLE: with REASON version, packages and provides with this name
+ */
+type Unsatisfied struct {
+	NAME      string
+	VERSION   string
+	REPO      string
+	PACKAGER  string
+	TYPE      string // depends, makedepends, optdepends
+	DEPEND    string
+	REASON    string
+	AVAILABLE []string `json:",omitempty"`
+}
+
+/*
+ * all dependencies without selected package (field pkg filled by GenSqlite)
+ * sorted by repo, packager, package
+ */
+func CheckDepends(db *sql.DB, withMake bool, withOpt bool) ([]Unsatisfied, error) {
+	ret := []Unsatisfied{}
+	for _, table := range []string{"depends", "makedepends", "optdepends"} {
+		name, depend := table+".depend", table+".depend||"+table+".comp||"+table+".ver"
+		switch {
+		case table == "makedepends" && !withMake:
+			continue
+		case table == "optdepends":
+			if !withOpt {
+				continue
+			}
+			name, depend = "optdepends.optdepend", "optdepends.optdepend"
+		}
+		rows, err := db.Query("SELECT pkgs.name, pkgs.version, ifnull(repos.repo, ''), ifnull(packagers.packager, ''), " + depend + ", " +
+			"(SELECT group_concat(item, char(10)) FROM (SELECT p.name||'='||p.version AS item FROM pkgs p WHERE p.name=" + name +
+			" UNION ALL SELECT p.name||' ('||pr.provide||pr.comp||pr.ver||')' FROM provides pr INNER JOIN pkgs p ON p.id=pr.id WHERE pr.provide=" + name + ")) " +
+			"FROM " + table + " INNER JOIN pkgs ON pkgs.id=" + table + ".id LEFT JOIN repos ON repos.id=pkgs.repo LEFT JOIN packagers ON packagers.id=pkgs.packager " +
+			"WHERE " + table + ".pkg IS NULL OR " + table + ".pkg<0")
+		if err != nil {
+			return ret, err
+		}
+		for rows.Next() {
+			u := Unsatisfied{TYPE: table, REASON: CHECK_MISSING}
+			var available sql.NullString
+			if err := rows.Scan(&u.NAME, &u.VERSION, &u.REPO, &u.PACKAGER, &u.DEPEND, &available); err != nil {
+				rows.Close()
+				return ret, err
+			}
+			if available.Valid {
+				u.REASON = CHECK_VERSION
+				u.AVAILABLE = strings.Split(available.String, "\n")
+			}
+			ret = append(ret, u)
+		}
+		rows.Close()
+	}
+
+	// repos in pacman order
+	repos := map[string]int{}
+	if names, err := queryStrings(db, "SELECT repo FROM repos ORDER BY id"); err == nil {
+		for i, name := range names {
+			repos[name] = i
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		a, b := ret[i], ret[j]
+		if a.REPO != b.REPO {
+			return repos[a.REPO] < repos[b.REPO]
+		}
+		if a.PACKAGER != b.PACKAGER {
+			return a.PACKAGER < b.PACKAGER
+		}
+		return a.NAME < b.NAME
+	})
+	return ret, nil
+}
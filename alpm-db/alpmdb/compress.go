@@ -0,0 +1,49 @@
+package alpmdb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+var (
+	magicGzip  = []byte{0x1f, 0x8b}
+	magicZstd  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	magicXz    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	magicBzip2 = []byte{'B', 'Z', 'h'}
+)
+
+/*
+ * uncompress a db (repo-add can use gzip, zstd, xz, bzip2 or none)
+ * compression is detected by magic bytes
+ */
+func openDbStream(stream io.Reader) (io.ReadCloser, error) {
+	buf := bufio.NewReader(stream)
+	magic, _ := buf.Peek(6)
+
+	switch {
+	case bytes.HasPrefix(magic, magicGzip):
+		return gzip.NewReader(buf)
+	case bytes.HasPrefix(magic, magicZstd):
+		dec, err := zstd.NewReader(buf)
+		if err != nil {
+			return nil, err
+		}
+		return dec.IOReadCloser(), nil
+	case bytes.HasPrefix(magic, magicXz):
+		dec, err := xz.NewReader(buf)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(dec), nil
+	case bytes.HasPrefix(magic, magicBzip2):
+		return io.NopCloser(bzip2.NewReader(buf)), nil
+	}
+	// tar without compression
+	return io.NopCloser(buf), nil
+}
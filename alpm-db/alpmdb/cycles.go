@@ -0,0 +1,191 @@
+package alpmdb
+
+import (
+	"database/sql"
+	"sort"
+)
+
+/*
+ * strongly connected packages: each package needs all others, directly or not
+ * TYPE: depends (runtime) or makedepends (build)
+ * EDGES: relations between packages of the cycle
+ */
+type Cycle struct {
+	TYPE     string
+	PACKAGES []string
+	EDGES    []GraphEdge
+}
+
+type cycleNode struct {
+	name  string
+	edges []GraphEdge
+	to    []int32
+	index int
+	low   int
+	stack bool
+}
+
+/*
+ * edges between packages, provides are resolved (field pkg filled by GenSqlite)
+ */
+func loadCycleNodes(db *sql.DB, tables []string) (map[int32]*cycleNode, error) {
+	nodes := map[int32]*cycleNode{}
+	rows, err := db.Query("SELECT id, name FROM pkgs")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		n := cycleNode{index: -1}
+		var id int32
+		if err := rows.Scan(&id, &n.name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		nodes[id] = &n
+	}
+	rows.Close()
+
+	for _, table := range tables {
+		rows, err := db.Query("SELECT " + table + ".id, " + table + ".pkg, " + table + ".depend, " + table + ".depend||" + table + ".comp||" + table + ".ver FROM " + table +
+			" WHERE " + table + ".pkg>=0 ORDER BY " + table + ".rowid")
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var from, to int32
+			var name, depend string
+			if err := rows.Scan(&from, &to, &name, &depend); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			source, target := nodes[from], nodes[to]
+			if source == nil || target == nil {
+				continue
+			}
+			source.to = append(source.to, to)
+			source.edges = append(source.edges, GraphEdge{FROM: source.name, TO: target.name, TYPE: table, DEPEND: depend, PROVIDED: name != target.name})
+		}
+		rows.Close()
+	}
+	return nodes, nil
+}
+
+/*
+ * tarjan: strongly connected components with more than one package, or a package that needs itself
+ */
+func stronglyConnected(nodes map[int32]*cycleNode) [][]int32 {
+	ids := make([]int32, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	ret := [][]int32{}
+	index := 0
+	stack := []int32{}
+	var connect func(id int32)
+	connect = func(id int32) {
+		n := nodes[id]
+		n.index, n.low = index, index
+		index++
+		stack = append(stack, id)
+		n.stack = true
+		for _, to := range n.to {
+			next := nodes[to]
+			if next.index < 0 {
+				connect(to)
+				if next.low < n.low {
+					n.low = next.low
+				}
+			} else if next.stack && next.index < n.low {
+				n.low = next.index
+			}
+		}
+		if n.low != n.index {
+			return
+		}
+		component := []int32{}
+		for {
+			last := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			nodes[last].stack = false
+			component = append(component, last)
+			if last == id {
+				break
+			}
+		}
+		if len(component) > 1 {
+			ret = append(ret, component)
+			return
+		}
+		for _, to := range n.to {
+			if to == id {
+				ret = append(ret, component)
+				break
+			}
+		}
+	}
+	for _, id := range ids {
+		if nodes[id].index < 0 {
+			connect(id)
+		}
+	}
+	return ret
+}
+
+/*
+ * dependency cycles
+ * depends: runtime graph
+ * makedepends: build graph, makedepends and depends (a makedepend is installed with its depends),
+ * only cycles with at least one makedepends
+ * biggest cycles first
+ */
+func FindCycles(db *sql.DB, table string) ([]Cycle, error) {
+	tables := []string{"depends"}
+	if table == "makedepends" {
+		tables = append(tables, "makedepends")
+	}
+	nodes, err := loadCycleNodes(db, tables)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []Cycle{}
+	for _, component := range stronglyConnected(nodes) {
+		members := make(map[int32]bool, len(component))
+		for _, id := range component {
+			members[id] = true
+		}
+		cycle := Cycle{TYPE: table, PACKAGES: []string{}, EDGES: []GraphEdge{}}
+		withType := false
+		for _, id := range component {
+			n := nodes[id]
+			cycle.PACKAGES = append(cycle.PACKAGES, n.name)
+			for i, to := range n.to {
+				if members[to] {
+					cycle.EDGES = append(cycle.EDGES, n.edges[i])
+					withType = withType || n.edges[i].TYPE == table
+				}
+			}
+		}
+		if !withType {
+			// only depends in build graph: already a runtime cycle
+			continue
+		}
+		sort.Strings(cycle.PACKAGES)
+		sort.SliceStable(cycle.EDGES, func(i, j int) bool {
+			if cycle.EDGES[i].FROM != cycle.EDGES[j].FROM {
+				return cycle.EDGES[i].FROM < cycle.EDGES[j].FROM
+			}
+			return cycle.EDGES[i].TO < cycle.EDGES[j].TO
+		})
+		ret = append(ret, cycle)
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		if len(ret[i].PACKAGES) != len(ret[j].PACKAGES) {
+			return len(ret[i].PACKAGES) > len(ret[j].PACKAGES)
+		}
+		return ret[i].PACKAGES[0] < ret[j].PACKAGES[0]
+	})
+	return ret, nil
+}
@@ -0,0 +1,178 @@
+package alpmdb
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+ * tables of desc fields REPLACES, GROUPS, CHECKDEPENDS, XDATA and checksums
+ * sonames: libraries in PROVIDES (libssl.so=3-64)
+ * checkdepends.pkg: selected package as depends.pkg
+ */
+var descTables = []string{
+	"CREATE TABLE IF NOT EXISTS replaces (id INTEGER, replace TEXT, comp TEXT, ver TEXT)",
+	"CREATE TABLE IF NOT EXISTS groups (id INTEGER, groupname TEXT)",
+	"CREATE TABLE IF NOT EXISTS checkdepends (id INTEGER, depend TEXT, comp TEXT, ver TEXT, pkg INTEGER DEFAULT -1)",
+	"CREATE TABLE IF NOT EXISTS checksums (id INTEGER PRIMARY KEY, md5sum TEXT, sha256sum TEXT, pgpsig TEXT)",
+	"CREATE TABLE IF NOT EXISTS xdata (id INTEGER, key TEXT, value TEXT)",
+	"CREATE TABLE IF NOT EXISTS sonames (id INTEGER, soname TEXT, version TEXT, abi TEXT)",
+	"CREATE INDEX IF NOT EXISTS index_soname ON sonames (soname)",
+}
+
+/*
+ * rows of a package for a table
+ */
+type descRelation struct {
+	table  string
+	fields []string
+	rows   [][]interface{}
+}
+
+func (r *descRelation) request(nb int) string {
+	values := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(r.fields)), ", ") + ")"
+	return "INSERT INTO " + r.table + " (" + strings.Join(r.fields, ", ") + ") VALUES " + strings.TrimSuffix(strings.Repeat(values+", ", nb), ", ")
+}
+
+// tables and fields of descTables, in descRelations order
+var descFields = []descRelation{
+	{table: "replaces", fields: []string{"id", "replace", "comp", "ver"}},
+	{table: "groups", fields: []string{"id", "groupname"}},
+	{table: "checkdepends", fields: []string{"id", "depend", "comp", "ver", "pkg"}},
+	{table: "checksums", fields: []string{"id", "md5sum", "sha256sum", "pgpsig"}},
+	{table: "xdata", fields: []string{"id", "key", "value"}},
+	{table: "sonames", fields: []string{"id", "soname", "version", "abi"}},
+}
+
+func descRelations(pkg *Package, resolver *Resolver) []descRelation {
+	ret := make([]descRelation, len(descFields))
+	copy(ret, descFields)
+	for _, dep := range pkg.REPLACES {
+		name, comp, ver := splitDepend(dep)
+		ret[0].rows = append(ret[0].rows, []interface{}{pkg.id, name, comp, ver})
+	}
+	for _, group := range pkg.GROUPS {
+		ret[1].rows = append(ret[1].rows, []interface{}{pkg.id, group})
+	}
+	for _, dep := range pkg.CHECKDEPENDS {
+		name, comp, ver := splitDepend(dep)
+		ret[2].rows = append(ret[2].rows, []interface{}{pkg.id, name, comp, ver, resolver.Preferred(dep)})
+	}
+	if pkg.MD5SUM != "" || pkg.SHA256SUM != "" || pkg.PGPSIG != "" {
+		ret[3].rows = append(ret[3].rows, []interface{}{pkg.id, pkg.MD5SUM, pkg.SHA256SUM, pkg.PGPSIG})
+	}
+	for _, data := range pkg.XDATA {
+		kv := strings.SplitN(data, "=", 2)
+		if len(kv) < 2 {
+			kv = append(kv, "")
+		}
+		ret[4].rows = append(ret[4].rows, []interface{}{pkg.id, kv[0], kv[1]})
+	}
+	for _, provide := range pkg.PROVIDES {
+		if so, ok := parseSoname(provide); ok {
+			ret[5].rows = append(ret[5].rows, []interface{}{pkg.id, so.NAME, so.VERSION, so.ABI})
+		}
+	}
+	return ret
+}
+
+/*
+ * GenSqlite: fill descTables, by requests of at most 500 variables (sqlite limit: 999)
+ * a package can have many rows (groups, xdata, sonames): limit is checked by row
+ */
+func genSqliteDesc(db sqlExecer, pkgs Packages, resolver *Resolver) error {
+	for _, table := range descTables {
+		if _, err := db.Exec(table); err != nil {
+			return err
+		}
+	}
+	pending := map[string]*descRelation{}
+	flush := func(r *descRelation) error {
+		if len(r.rows) < 1 {
+			return nil
+		}
+		vals := make([]interface{}, 0, len(r.rows)*len(r.fields))
+		for _, row := range r.rows {
+			vals = append(vals, row...)
+		}
+		if _, err := db.Exec(r.request(len(r.rows)), vals...); err != nil {
+			return fmt.Errorf("%s insert: %s", r.table, err)
+		}
+		r.rows = nil
+		return nil
+	}
+	for i := range pkgs {
+		for _, r := range descRelations(&pkgs[i], resolver) {
+			p, found := pending[r.table]
+			if !found {
+				p = &descRelation{table: r.table, fields: r.fields}
+				pending[r.table] = p
+			}
+			for _, row := range r.rows {
+				if (len(p.rows)+1)*len(p.fields) > 500 {
+					if err := flush(p); err != nil {
+						return err
+					}
+				}
+				p.rows = append(p.rows, row)
+			}
+		}
+	}
+	for _, p := range pending {
+		if err := flush(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * UpdateSqlite: descTables not in pacman.db, created empty
+ */
+func missingDescTables(db sqlQueryer) map[string]bool {
+	ret := map[string]bool{}
+	for _, r := range descFields {
+		if !hasTable(db, "main", r.table) {
+			ret[r.table] = true
+		}
+	}
+	return ret
+}
+
+/*
+ * UpdateSqlite: fill tables for all packages, unchanged packages are not inserted again
+ */
+func fillDescTables(db sqlExecer, tables map[string]bool, pkgs Packages, resolver *Resolver) error {
+	for table := range tables {
+		if _, err := db.Exec("DELETE FROM " + table); err != nil {
+			return err
+		}
+	}
+	for i := range pkgs {
+		for _, r := range descRelations(&pkgs[i], resolver) {
+			if !tables[r.table] {
+				continue
+			}
+			for _, row := range r.rows {
+				if _, err := db.Exec(r.request(1), row...); err != nil {
+					return fmt.Errorf("%s: %s", pkgs[i].NAME, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+/*
+ * UpdateSqlite: insert relations of one package
+ */
+func insertDesc(db sqlExecer, pkg *Package, resolver *Resolver) error {
+	for _, r := range descRelations(pkg, resolver) {
+		for _, row := range r.rows {
+			if _, err := db.Exec(r.request(1), row...); err != nil {
+				return fmt.Errorf("%s: %s", pkg.NAME, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package alpmdb
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func countRows(t *testing.T, dbFile string, table string) int {
+	t.Helper()
+	db, err := OpenReadOnly(dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var nb int
+	if err := db.QueryRow("SELECT count(*) FROM " + table).Scan(&nb); err != nil {
+		t.Fatal(err)
+	}
+	return nb
+}
+
+func manyValues(format string, nb int) []string {
+	ret := make([]string, nb)
+	for i := range ret {
+		ret[i] = fmt.Sprintf(format, i)
+	}
+	return ret
+}
+
+/*
+ * a batch just under the limit, then a package with many rows:
+ * requests must stay under 999 variables
+ */
+func TestGenSqliteDescBatches(t *testing.T) {
+	pkgs := testPackages(
+		// 249 groups: 498 variables, not flushed
+		Package{NAME: "a", VERSION: "1.0-1", REPO: "core", GROUPS: manyValues("group%d", 249), XDATA: manyValues("key%d=value", 166)},
+		Package{NAME: "b", VERSION: "1.0-1", REPO: "core", GROUPS: manyValues("other%d", 300), XDATA: manyValues("other%d=value", 300)},
+	)
+	dbFile := filepath.Join(t.TempDir(), "batches.db")
+	if err := GenSqlite(dbFile, pkgs, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if nb := countRows(t, dbFile, "groups"); nb != 549 {
+		t.Errorf("groups rows = %d, want 549", nb)
+	}
+	if nb := countRows(t, dbFile, "xdata"); nb != 466 {
+		t.Errorf("xdata rows = %d, want 466", nb)
+	}
+}
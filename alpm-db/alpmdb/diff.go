@@ -0,0 +1,83 @@
+package alpmdb
+
+import (
+	"sort"
+)
+
+const (
+	DIFF_ADDED      = "added"
+	DIFF_REMOVED    = "removed"
+	DIFF_UPGRADED   = "upgraded"
+	DIFF_DOWNGRADED = "downgraded"
+	DIFF_UPDATED    = "updated" // same version, other content (repo, depends...)
+)
+
+/*
+ * one package change between 2 branches, or between 2 updates of pacman.db
+ */
+type PackageDiff struct {
+	NAME       string
+	STATUS     string
+	OLDVERSION string `json:",omitempty"`
+	NEWVERSION string `json:",omitempty"`
+	OLDREPO    string `json:",omitempty"`
+	NEWREPO    string `json:",omitempty"`
+}
+
+/*
+ * index packages by name, as pacman first repo wins
+ */
+func packagesByName(pkgs Packages) map[string]*Package {
+	ret := make(map[string]*Package, len(pkgs))
+	for i := range pkgs {
+		if _, found := ret[pkgs[i].NAME]; !found {
+			ret[pkgs[i].NAME] = &pkgs[i]
+		}
+	}
+	return ret
+}
+
+/*
+ * compare packages of 2 branches
+ * sorted by status then name
+ */
+func DiffPackages(oldPkgs Packages, newPkgs Packages) []PackageDiff {
+	ret := []PackageDiff{}
+	olds := packagesByName(oldPkgs)
+	news := packagesByName(newPkgs)
+
+	for name, n := range news {
+		o, found := olds[name]
+		if !found {
+			ret = append(ret, PackageDiff{NAME: name, STATUS: DIFF_ADDED, NEWVERSION: n.VERSION, NEWREPO: n.REPO})
+			continue
+		}
+		status := ""
+		switch Vercmp(n.VERSION, o.VERSION) {
+		case 1:
+			status = DIFF_UPGRADED
+		case -1:
+			status = DIFF_DOWNGRADED
+		default:
+			continue
+		}
+		ret = append(ret, PackageDiff{NAME: name, STATUS: status, OLDVERSION: o.VERSION, NEWVERSION: n.VERSION, OLDREPO: o.REPO, NEWREPO: n.REPO})
+	}
+	for name, o := range olds {
+		if _, found := news[name]; !found {
+			ret = append(ret, PackageDiff{NAME: name, STATUS: DIFF_REMOVED, OLDVERSION: o.VERSION, OLDREPO: o.REPO})
+		}
+	}
+
+	sortDiffs(ret)
+	return ret
+}
+
+func sortDiffs(diffs []PackageDiff) {
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].STATUS != diffs[j].STATUS {
+			return diffs[i].STATUS < diffs[j].STATUS
+		}
+		return diffs[i].NAME < diffs[j].NAME
+	})
+}
@@ -0,0 +1,264 @@
+package alpmdb
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+/*
+ * cache metadata of a downloaded file: localFile.meta
+ */
+type fileMeta struct {
+	URL          string
+	LastModified string `json:",omitempty"`
+	ETag         string `json:",omitempty"`
+	Size         int64
+	Sha256       string
+}
+
+func fileSha256(filename string) (string, int64, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	nb, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nb, nil
+}
+
+/*
+ * metadata only if cached file is same as downloaded
+ */
+func loadFileMeta(localFile string) *fileMeta {
+	content, err := os.ReadFile(localFile + ".meta")
+	if err != nil {
+		return nil
+	}
+	meta := fileMeta{}
+	if err := json.Unmarshal(content, &meta); err != nil {
+		return nil
+	}
+	sum, size, err := fileSha256(localFile)
+	if err != nil || sum != meta.Sha256 || size != meta.Size {
+		return nil
+	}
+	return &meta
+}
+
+func saveFileMeta(localFile string, meta fileMeta) error {
+	sum, size, err := fileSha256(localFile)
+	if err != nil {
+		return err
+	}
+	meta.Sha256 = sum
+	meta.Size = size
+	content, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(localFile+".meta", content, 0644)
+}
+
+/*
+ * file:// server of pacman.conf (local repo): file is copied
+ * return false if cached file is the same
+ */
+func copyLocalFile(url string, localFile string) (bool, error) {
+	source := strings.TrimPrefix(url, "file://")
+	sum, _, err := fileSha256(source)
+	if err != nil {
+		return false, fmt.Errorf("file error: %s: %v", url, err)
+	}
+	if meta := loadFileMeta(localFile); meta != nil && meta.URL == url && meta.Sha256 == sum {
+		return false, nil
+	}
+	in, err := os.Open(source)
+	if err != nil {
+		return false, fmt.Errorf("file error: %s: %v", url, err)
+	}
+	defer in.Close()
+	out, err := os.Create(localFile + ".part")
+	if err != nil {
+		return false, fmt.Errorf("os error: %s: %v", url, err)
+	}
+	_, err = io.Copy(out, in)
+	out.Close()
+	if err != nil {
+		os.Remove(localFile + ".part")
+		return false, fmt.Errorf("io error: %s: %v", url, err)
+	}
+	if err := os.Rename(localFile+".part", localFile); err != nil {
+		return false, err
+	}
+	return true, saveFileMeta(localFile, fileMeta{URL: url})
+}
+
+/*
+ * download one file, partial file is never kept
+ * conditional request (If-Modified-Since / If-None-Match) if file in cache
+ * file:// urls are copied
+ * return false if cache is used (http 304)
+ */
+func HttpGetFile(url string, localFile string) (bool, error) {
+	if strings.HasPrefix(url, "file://") {
+		return copyLocalFile(url, localFile)
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return false, err
+	}
+	// same url only: other mirror or branch has other dates
+	if meta := loadFileMeta(localFile); meta != nil && meta.URL == url {
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+	}
+	client := http.Client{Timeout: time.Duration(25) * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return false, nil
+	}
+	if resp.StatusCode > 399 {
+		return false, fmt.Errorf("http error: %s: %v", url, resp.StatusCode)
+	}
+	out, err := os.Create(localFile + ".part")
+	if err != nil {
+		return false, fmt.Errorf("os error: %s: %v", url, err)
+	}
+	nb, err := io.Copy(out, resp.Body)
+	out.Close()
+	if err == nil && resp.ContentLength > 0 && nb != resp.ContentLength {
+		err = fmt.Errorf("truncated %d/%d", nb, resp.ContentLength)
+	}
+	if err != nil {
+		os.Remove(localFile + ".part")
+		return false, fmt.Errorf("io error: %s: %v", url, err)
+	}
+	if err := os.Rename(localFile+".part", localFile); err != nil {
+		return false, err
+	}
+	err = saveFileMeta(localFile, fileMeta{
+		URL:          url,
+		LastModified: resp.Header.Get("Last-Modified"),
+		ETag:         resp.Header.Get("ETag"),
+	})
+	return true, err
+}
+
+/*
+ * result of a repo download
+ * URL is empty if no mirror is available
+ */
+type DownloadResult struct {
+	REPO    string
+	URL     string
+	CHANGED bool
+	ERRORS  []error // one by mirror tried
+}
+
+/*
+ * servers: as pacman.conf Server, without $repo and $arch
+ * try servers in order until one works
+ * ext: ".db" or ".files"
+ * withSig: download signature file (.db.sig)
+ */
+func HttpGetDb(servers []string, repo string, ext string, localDir string, withSig bool) DownloadResult {
+	ret := DownloadResult{REPO: repo}
+	localFile := localDir + "/" + repo + ext
+	for _, server := range servers {
+		url := server + "/" + repo + ext
+		changed, err := HttpGetFile(url, localFile)
+		if err == nil {
+			if withSig {
+				// same mirror as db
+				if _, err := HttpGetFile(url+".sig", localFile+".sig"); err != nil {
+					os.Remove(localFile + ".sig")
+					os.Remove(localFile + ".sig.meta")
+				}
+			}
+			ret.URL = url
+			ret.CHANGED = changed
+			return ret
+		}
+		ret.ERRORS = append(ret.ERRORS, err)
+	}
+	// cache is not valid now
+	ret.CHANGED = true
+	return ret
+}
+
+/*
+ * manjaro ARM branches are arm-stable, arm-testing, arm-unstable
+ */
+func ManjaroBranch(branch string, arch string) string {
+	if arch != DefaultArch && !strings.HasPrefix(branch, "arm-") {
+		return "arm-" + branch
+	}
+	return branch
+}
+
+/*
+ * servers for mirrors urls and a branch
+ * archLayout: archlinux layout ($repo/os/$arch), or $arch/$repo for other arches (Arch Linux ARM, archlinux32)
+ * else manjaro ($branch/$repo/$arch)
+ */
+func MirrorServers(urls []string, branch string, repos []string, archLayout bool, arch string) map[string][]string {
+	ret := make(map[string][]string, len(repos))
+	for _, url := range urls {
+		server := url + "/" + ManjaroBranch(branch, arch) + "/$repo/$arch"
+		if archLayout {
+			server = url + "/$repo/os/$arch"
+			if arch != DefaultArch {
+				server = url + "/$arch/$repo"
+			}
+		}
+		for _, repo := range repos {
+			ret[repo] = append(ret[repo], substituteServer(server, repo, arch))
+		}
+	}
+	return ret
+}
+
+/*
+ * servers of a pacman mirrorlist file (same for all repos)
+ */
+func ParseMirrorlist(filename string, repos []string, arch string) (map[string][]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ret := make(map[string][]string, len(repos))
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.SplitN(scanner.Text(), "#", 2)[0])
+		tmp := strings.SplitN(line, "=", 2)
+		if len(tmp) < 2 || strings.TrimSpace(tmp[0]) != "Server" {
+			continue
+		}
+		for _, repo := range repos {
+			ret[repo] = append(ret[repo], substituteServer(strings.TrimSpace(tmp[1]), repo, arch))
+		}
+	}
+	return ret, scanner.Err()
+}
@@ -0,0 +1,104 @@
+package alpmdb
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+)
+
+/*
+ * source: https://gist.github.com/indraniel/1a91458984179ab4cf80
+ */
+func ExtractTarGz(gzipStream io.Reader, pkgs Packages, repo string, filters PackageFilter) (Packages, error) {
+	if len(filters) > 0 && len(pkgs) == len(filters) {
+		return pkgs, nil
+	}
+	uncompressedStream, err := openDbStream(gzipStream)
+	if err != nil {
+		return pkgs, fmt.Errorf("ExtractTarGz: NewReader failed: %s", err.Error())
+	}
+	defer uncompressedStream.Close()
+
+	tarReader := tar.NewReader(uncompressedStream)
+	// .files db: package dir -> index in pkgs, for the files entry
+	dirs := make(map[string]int)
+	files := make(map[string]string)
+	// package dir not in filters: files entry is ignored
+	skipped := make(map[string]bool)
+
+	for true {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return pkgs, fmt.Errorf("ExtractTarGz: Next() failed: %s", err.Error())
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			/*fmt.Println("::dir:",header.Name)*/
+			// previous package is complete (desc and files)
+			if len(filters) == 1 && len(pkgs) > 0 {
+				return pkgs, nil
+			}
+		case tar.TypeReg:
+
+			buf := new(bytes.Buffer)
+
+			if _, err := buf.ReadFrom(tarReader); err != nil {
+				if err != io.EOF {
+					return pkgs, fmt.Errorf("ExtractTarGz:  failed: %s", err.Error())
+				}
+			}
+
+			dir, name := path.Split(header.Name)
+			if name == "files" {
+				if skipped[dir] {
+					continue
+				}
+				if i, found := dirs[dir]; found {
+					pkgs[i].SetFiles(buf.String())
+				} else {
+					// files before desc in archive
+					files[dir] = buf.String()
+				}
+				continue
+			}
+			if name != "desc" {
+				continue
+			}
+
+			pkg := Package{
+				dir:  header.Name,
+				REPO: repo,
+				id:   int32(len(pkgs)) + 1,
+			}
+			if pkg.Set(string(buf.Bytes())) {
+				if len(filters) > 0 {
+					if _, found := filters[pkg.NAME]; !found {
+						delete(files, dir)
+						skipped[dir] = true
+						continue
+					}
+					//pkgs = append(pkgs, pkg)
+				}
+				if content, found := files[dir]; found {
+					pkg.SetFiles(content)
+					delete(files, dir)
+				}
+				dirs[dir] = len(pkgs)
+				pkgs = append(pkgs, pkg)
+			}
+		default:
+			return pkgs, fmt.Errorf(
+				"ExtractTarGz: uknown type: %c in %s",
+				header.Typeflag,
+				header.Name)
+		}
+	}
+	return pkgs, nil
+}
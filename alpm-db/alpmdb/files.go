@@ -0,0 +1,142 @@
+package alpmdb
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+/*
+ * files table, only filled with .files db (--files)
+ * a lot of rows: one transaction
+ */
+func genSqliteFiles(db *sql.DB, pkgs Packages) error {
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS files (id INTEGER, file TEXT)"); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare("INSERT INTO files (id, file) VALUES (?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	nb := 0
+	for _, pkg := range pkgs {
+		for _, file := range pkg.FILES {
+			if _, err := stmt.Exec(pkg.id, file); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("files insert %s %s: %s", pkg.NAME, file, err)
+			}
+			nb++
+		}
+	}
+	stmt.Close()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if nb > 0 {
+		if _, err := db.Exec("CREATE INDEX index_file ON files (file ASC)"); err != nil {
+			return err
+		}
+	}
+	logln(nb, "files")
+	return nil
+}
+
+/*
+ * pacman.db generated with .files db (sync --files) ?
+ */
+func HasFiles(db *sql.DB) bool {
+	var id int32
+	return db.QueryRow("SELECT id FROM files LIMIT 1").Scan(&id) == nil
+}
+
+/*
+ * sql condition for a path search
+ * "/usr/bin/foo": full path, "foo": file name, "*foo*": glob, --regex: regular expression
+ * pacman stores paths without first "/"
+ */
+func FilesCondition(search string, regex bool) (string, []interface{}) {
+	if regex {
+		return "files.file REGEXP ?", []interface{}{search}
+	}
+	search = strings.TrimPrefix(search, "/")
+	isGlob := strings.ContainsAny(search, "*?[")
+	if !strings.Contains(search, "/") {
+		// file name, not directories
+		if isGlob {
+			return "(files.file GLOB ? AND files.file NOT GLOB '*/')", []interface{}{"*/" + search}
+		}
+		return "(files.file = ? OR files.file GLOB ?)", []interface{}{search, "*/" + search}
+	}
+	if isGlob {
+		return "files.file GLOB ?", []interface{}{search}
+	}
+	return "files.file = ?", []interface{}{search}
+}
+
+/*
+ * a file in a package
+ */
+type FileOwner struct {
+	REPO    string
+	NAME    string
+	VERSION string
+	FILE    string
+}
+
+/*
+ * which packages have this file
+ */
+func Owns(db *sql.DB, search string, regex bool) ([]FileOwner, error) {
+	where, args := FilesCondition(search, regex)
+	rows, err := db.Query("SELECT repos.repo, pkgs.name, pkgs.version, files.file FROM files INNER JOIN pkgs ON pkgs.id=files.id LEFT JOIN repos ON repos.id=pkgs.repo WHERE "+where+" ORDER BY pkgs.name, files.file", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := []FileOwner{}
+	for rows.Next() {
+		var repo sql.NullString
+		item := FileOwner{}
+		if err := rows.Scan(&repo, &item.NAME, &item.VERSION, &item.FILE); err != nil {
+			return ret, err
+		}
+		item.REPO = repo.String
+		ret = append(ret, item)
+	}
+	return ret, rows.Err()
+}
+
+/*
+ * files of a package, search is optional
+ */
+func ListFiles(db *sql.DB, name string, search string, regex bool) ([]string, error) {
+	request := "SELECT files.file FROM files INNER JOIN pkgs ON pkgs.id=files.id WHERE pkgs.name=?"
+	args := []interface{}{name}
+	if search != "" {
+		where, values := FilesCondition(search, regex)
+		request += " AND " + where
+		args = append(args, values...)
+	}
+	rows, err := db.Query(request+" ORDER BY files.file", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := []string{}
+	for rows.Next() {
+		var file string
+		if err := rows.Scan(&file); err != nil {
+			return ret, err
+		}
+		ret = append(ret, file)
+	}
+	return ret, rows.Err()
+}
@@ -0,0 +1,221 @@
+package alpmdb
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"strings"
+)
+
+/*
+ * full text index of packages of all synced branches, rowid is branch_pkgs.rowid
+ * fts5 if sqlite3 is built with it (go build -tags sqlite_fts5, see Makefile), else fts4
+ */
+const (
+	ftsTable   = "branch_fts"
+	ftsColumns = "name, desc, provides, groups"
+)
+
+// column weights for ranking: name, desc, provides, groups
+var ftsWeights = []float64{10, 1, 5, 3}
+
+/*
+ * a package found by Search
+ * SNIPPETNAME and SNIPPET: name and description with matches highlighted
+ */
+type SearchResult struct {
+	NAME        string
+	VERSION     string
+	REPO        string
+	BRANCH      string `json:",omitempty"`
+	ARCH        string `json:",omitempty"`
+	DESC        string
+	SNIPPETNAME string `json:"-"`
+	SNIPPET     string `json:"-"`
+}
+
+// *sql.DB or *sql.Tx
+type sqlDb interface {
+	sqlExecer
+	sqlQueryer
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func hasFts5(db sqlDb) bool {
+	var fts5 bool
+	err := db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5)
+	return err == nil && fts5
+}
+
+/*
+ * sqlite3 of this build has fts5, else index is fts4 (other ranking and query syntax)
+ */
+func Fts5Available() bool {
+	db, err := sql.Open(SqlDriver, ":memory:")
+	if err != nil {
+		return false
+	}
+	defer db.Close()
+	return hasFts5(db)
+}
+
+/*
+ * (re)create the index from branch_pkgs
+ */
+func genSqliteFts(db sqlDb) error {
+	if _, err := db.Exec("DROP TABLE IF EXISTS " + ftsTable); err != nil {
+		return err
+	}
+	module := "fts4"
+	if hasFts5(db) {
+		module = "fts5"
+	} else {
+		logln("sqlite3 without fts5 (go build -tags sqlite_fts5): fts4 index")
+	}
+	if _, err := db.Exec("CREATE VIRTUAL TABLE " + ftsTable + " USING " + module + "(" + ftsColumns + ")"); err != nil {
+		return err
+	}
+	_, err := db.Exec("INSERT INTO " + ftsTable + " (rowid, " + ftsColumns + ") SELECT rowid, name, ifnull(desc, ''), ifnull(provides, ''), ifnull(groups, '') FROM branch_pkgs")
+	return err
+}
+
+/*
+ * version of the index: "fts5", "fts4" or "" if not exists or not usable
+ */
+func ftsVersion(db *sql.DB) string {
+	var request string
+	if err := db.QueryRow("SELECT sql FROM sqlite_master WHERE name=?", ftsTable).Scan(&request); err != nil {
+		return ""
+	}
+	if !strings.Contains(request, "fts5") {
+		return "fts4"
+	}
+	// sqlite3 built without fts5: index not usable
+	rows, err := db.Query("SELECT rowid FROM " + ftsTable + " LIMIT 0")
+	if err != nil {
+		return ""
+	}
+	rows.Close()
+	return "fts5"
+}
+
+/*
+ * sql function ftsrank(matchinfo(pkgs_fts, 'pcx')) for fts4, higher is better
+ * sum by phrase and column: weight * hits in row / hits in all rows
+ */
+func ftsRank(info []byte) float64 {
+	values := make([]uint32, len(info)/4)
+	for i := range values {
+		// matchinfo: native byte order
+		values[i] = binary.NativeEndian.Uint32(info[i*4:])
+	}
+	if len(values) < 2 {
+		return 0
+	}
+	phrases, cols := int(values[0]), int(values[1])
+	rank := 0.0
+	for p := 0; p < phrases; p++ {
+		for c := 0; c < cols && c < len(ftsWeights); c++ {
+			i := 2 + 3*(p*cols+c)
+			if i+1 >= len(values) || values[i+1] == 0 {
+				continue
+			}
+			rank += ftsWeights[c] * float64(values[i]) / float64(values[i+1])
+		}
+	}
+	return rank
+}
+
+/*
+ * MATCH expression: all terms, as prefix
+ */
+func ftsQuery(terms []string, version string) string {
+	items := []string{}
+	for _, term := range terms {
+		term = strings.ReplaceAll(term, "\"", "\"\"")
+		if term == "" {
+			continue
+		}
+		if version == "fts5" {
+			items = append(items, "\""+term+"\"*")
+		} else {
+			items = append(items, "\""+term+"*\"")
+		}
+	}
+	return strings.Join(items, " ")
+}
+
+/*
+ * packages with all terms (prefix) in name, description, provides or groups
+ * in all synced branches, or only in branch
+ * ordered by rank, exact name first
+ * start, end: around matches in SNIPPETNAME and SNIPPET
+ * without full text index (old pacman.db): terms in name or description
+ */
+func Search(db *sql.DB, terms []string, branch string, start string, end string) ([]SearchResult, error) {
+	var request string
+	var args []interface{}
+	first := ""
+	if len(terms) > 0 {
+		first = strings.ToLower(terms[0])
+	}
+	version := ftsVersion(db)
+	if version != "" && ftsQuery(terms, version) == "" {
+		return []SearchResult{}, nil
+	}
+	columns := "b.name, b.version, ifnull(b.repo, ''), ifnull(b.branch, ''), ifnull(b.arch, ''), ifnull(b.desc, ''), "
+	switch version {
+	case "fts5":
+		request = "SELECT " + columns +
+			"highlight(" + ftsTable + ", 0, ?, ?), snippet(" + ftsTable + ", 1, ?, ?, '...', 64) " +
+			"FROM " + ftsTable + " INNER JOIN branch_pkgs b ON b.rowid=" + ftsTable + ".rowid " +
+			"WHERE " + ftsTable + " MATCH ? AND (?='' OR b.branch=?) ORDER BY lower(b.name)=? DESC, bm25(" + ftsTable + ", 10.0, 1.0, 5.0, 3.0), b.name, b.branch"
+		args = []interface{}{start, end, start, end, ftsQuery(terms, version), branch, branch, first}
+	case "fts4":
+		request = "SELECT " + columns +
+			"snippet(" + ftsTable + ", ?, ?, '', 0, 64), snippet(" + ftsTable + ", ?, ?, '...', 1, 64) " +
+			"FROM " + ftsTable + " INNER JOIN branch_pkgs b ON b.rowid=" + ftsTable + ".rowid " +
+			"WHERE " + ftsTable + " MATCH ? AND (?='' OR b.branch=?) ORDER BY lower(b.name)=? DESC, ftsrank(matchinfo(" + ftsTable + ", 'pcx')) DESC, b.name, b.branch"
+		args = []interface{}{start, end, start, end, ftsQuery(terms, version), branch, branch, first}
+	default:
+		source := "branch_pkgs"
+		if !hasTable(db, "main", source) {
+			// only packages of pacman.db, repos.branch and repos.arch can be missing
+			fields := map[string]string{"branch": "NULL", "arch": "NULL"}
+			for column := range fields {
+				if hasColumn(db, "main", "repos", column) {
+					fields[column] = "repos." + column
+				}
+			}
+			source = "(SELECT pkgs.name AS name, pkgs.version AS version, repos.repo AS repo, " + fields["branch"] + " AS branch, " + fields["arch"] + " AS arch, pkgs.desc AS desc " +
+				"FROM pkgs LEFT JOIN repos ON repos.id=pkgs.repo)"
+		}
+		where := []string{"(?='' OR b.branch=?)"}
+		args = []interface{}{branch, branch}
+		for _, term := range terms {
+			where = append(where, "(b.name LIKE ? OR b.desc LIKE ?)")
+			args = append(args, "%"+term+"%", "%"+term+"%")
+		}
+		if len(terms) < 1 {
+			return []SearchResult{}, nil
+		}
+		args = append(args, first)
+		request = "SELECT " + columns + "b.name, ifnull(b.desc, '') " +
+			"FROM " + source + " b WHERE " + strings.Join(where, " AND ") + " ORDER BY lower(b.name)=? DESC, b.name, b.branch"
+	}
+
+	rows, err := db.Query(request, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := []SearchResult{}
+	for rows.Next() {
+		r := SearchResult{}
+		if err := rows.Scan(&r.NAME, &r.VERSION, &r.REPO, &r.BRANCH, &r.ARCH, &r.DESC, &r.SNIPPETNAME, &r.SNIPPET); err != nil {
+			return ret, err
+		}
+		ret = append(ret, r)
+	}
+	return ret, rows.Err()
+}
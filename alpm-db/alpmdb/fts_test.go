@@ -0,0 +1,59 @@
+package alpmdb
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+/*
+ * packages of stable and testing are found, after GenSqlite and UpdateSqlite
+ */
+func TestSearchBranches(t *testing.T) {
+	for _, update := range []bool{false, true} {
+		dbFile := filepath.Join(t.TempDir(), "search.db")
+		for _, branch := range []string{"stable", "testing"} {
+			pkgs := testBranch(branch)
+			pkgs[0].DESC = "The GNU Bourne Again shell"
+			pkgs[0].PROVIDES = []string{"sh"}
+			var err error
+			if update {
+				_, err = UpdateSqlite(dbFile, pkgs, nil, testInfos(branch))
+			} else {
+				err = GenSqlite(dbFile, pkgs, nil, testInfos(branch))
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		db, err := OpenReadOnly(dbFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			terms  []string
+			branch string
+			want   []string
+		}{
+			{[]string{"bourne"}, "", []string{"stable bash 5.3-1", "testing bash 5.4-1"}},
+			{[]string{"sh"}, "testing", []string{"testing bash 5.4-1"}},
+			{[]string{"gli"}, "", []string{"stable glibc 2.39-1", "testing glibc 2.39-1"}},
+			{[]string{"nope"}, "", []string{}},
+		}
+		for _, tt := range tests {
+			results, err := Search(db, tt.terms, tt.branch, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, r := range results {
+				got = append(got, r.BRANCH+" "+r.NAME+" "+r.VERSION)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("update %v: Search(%v, %q) = %v, want %v", update, tt.terms, tt.branch, got, tt.want)
+			}
+		}
+		db.Close()
+	}
+}
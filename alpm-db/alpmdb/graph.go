@@ -0,0 +1,157 @@
+package alpmdb
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+/*
+ * a package in a dependency graph
+ * MISSING: dependency not satisfied, NAME is the depend as declared
+ */
+type GraphNode struct {
+	id      int32
+	NAME    string
+	VERSION string `json:",omitempty"`
+	REPO    string `json:",omitempty"`
+	ROOT    bool   `json:",omitempty"`
+	MISSING bool   `json:",omitempty"`
+}
+
+/*
+ * FROM depends on TO, whatever the direction of the walk
+ * PROVIDED: TO is selected by its provides (DEPEND is not its name)
+ */
+type GraphEdge struct {
+	FROM     string
+	TO       string
+	TYPE     string // depends, makedepends, optdepends
+	DEPEND   string // as declared by FROM, without optdepends description
+	PROVIDED bool   `json:",omitempty"`
+}
+
+type Graph struct {
+	NODES []*GraphNode
+	EDGES []GraphEdge
+}
+
+/*
+ * relations to walk: table -> depend field
+ */
+func graphRelations(withMake bool, withOpt bool) map[string]string {
+	ret := map[string]string{"depends": "depends.depend||depends.comp||depends.ver"}
+	if withMake {
+		ret["makedepends"] = "makedepends.depend||makedepends.comp||makedepends.ver"
+	}
+	if withOpt {
+		ret["optdepends"] = "optdepends.optdepend"
+	}
+	return ret
+}
+
+/*
+ * edges of a package with the packages at the other end
+ * reverse: packages that depend on id, else dependencies of id
+ */
+func graphEdges(db *sql.DB, node *GraphNode, reverse bool, relations map[string]string) ([]GraphEdge, []*GraphNode, error) {
+	edges := []GraphEdge{}
+	nodes := []*GraphNode{}
+	for _, table := range []string{"depends", "makedepends", "optdepends"} {
+		depend, ok := relations[table]
+		if !ok {
+			continue
+		}
+		join, where := table+".pkg", table+".id"
+		if reverse {
+			join, where = table+".id", table+".pkg"
+		}
+		rows, err := db.Query("SELECT "+depend+", pkgs.id, pkgs.name, pkgs.version, repos.repo FROM "+table+
+			" LEFT JOIN pkgs ON pkgs.id="+join+" LEFT JOIN repos ON repos.id=pkgs.repo WHERE "+where+"=? ORDER BY "+depend, node.id)
+		if err != nil {
+			return edges, nodes, err
+		}
+		for rows.Next() {
+			var dep string
+			var id sql.NullInt32
+			var name, version, repo sql.NullString
+			if err := rows.Scan(&dep, &id, &name, &version, &repo); err != nil {
+				rows.Close()
+				return edges, nodes, err
+			}
+			dep = strings.TrimSpace(strings.SplitN(dep, ":", 2)[0])
+			depName, _, _ := splitDepend(dep)
+			other := &GraphNode{id: id.Int32, NAME: name.String, VERSION: version.String, REPO: repo.String}
+			if !id.Valid {
+				// with version: not the name of an existing package
+				other = &GraphNode{id: -1, NAME: dep, MISSING: true}
+			}
+			edge := GraphEdge{FROM: node.NAME, TO: other.NAME, TYPE: table, DEPEND: dep, PROVIDED: id.Valid && depName != name.String}
+			if reverse {
+				edge.FROM, edge.TO = other.NAME, node.NAME
+			}
+			edges = append(edges, edge)
+			nodes = append(nodes, other)
+		}
+		rows.Close()
+	}
+	return edges, nodes, nil
+}
+
+/*
+ * dependency graph of packages, level by level
+ * reverse: packages that need them
+ * depth < 1 : no limit
+ * uses field pkg (selected package, with provides) filled by GenSqlite
+ */
+func BuildGraph(db *sql.DB, names []string, reverse bool, depth int, withMake bool, withOpt bool) (*Graph, error) {
+	graph := Graph{NODES: []*GraphNode{}, EDGES: []GraphEdge{}}
+	relations := graphRelations(withMake, withOpt)
+	nodes := map[string]*GraphNode{}
+	level := []*GraphNode{}
+	for _, name := range names {
+		if _, found := nodes[name]; found {
+			continue
+		}
+		node := GraphNode{NAME: name, ROOT: true}
+		var repo sql.NullString
+		err := db.QueryRow("SELECT pkgs.id, pkgs.version, repos.repo FROM pkgs LEFT JOIN repos ON repos.id=pkgs.repo WHERE pkgs.name=?", name).Scan(&node.id, &node.VERSION, &repo)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("package %s not found in pacman.db", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		node.REPO = repo.String
+		nodes[name] = &node
+		graph.NODES = append(graph.NODES, &node)
+		level = append(level, &node)
+	}
+
+	edges := map[GraphEdge]bool{}
+	for d := 1; len(level) > 0 && (depth < 1 || d <= depth); d++ {
+		next := []*GraphNode{}
+		for _, parent := range level {
+			found, others, err := graphEdges(db, parent, reverse, relations)
+			if err != nil {
+				return &graph, err
+			}
+			for i, other := range others {
+				if !edges[found[i]] {
+					edges[found[i]] = true
+					graph.EDGES = append(graph.EDGES, found[i])
+				}
+				if _, seen := nodes[other.NAME]; seen {
+					continue
+				}
+				nodes[other.NAME] = other
+				graph.NODES = append(graph.NODES, other)
+				if !other.MISSING {
+					next = append(next, other)
+				}
+			}
+		}
+		level = next
+	}
+	return &graph, nil
+}
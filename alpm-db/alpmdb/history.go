@@ -0,0 +1,312 @@
+package alpmdb
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+	"time"
+)
+
+/*
+ * versions seen by syncs, kept by GenSqlite and UpdateSqlite
+ * one row by version change in a branch: added (no oldversion), removed (no newversion), upgraded or downgraded
+ * date: sync that saw the change
+ */
+const historyTable = "CREATE TABLE IF NOT EXISTS pkg_history (name TEXT, repo TEXT, branch TEXT, oldversion TEXT, newversion TEXT, builddate TIME, date TIME, arch TEXT)"
+
+/*
+ * packages of the last sync of each branch and architecture
+ * a sync is compared only with the last sync of the same branch
+ * also source of the full text index: search in all synced branches
+ * provides, groups: separated by space
+ */
+const (
+	branchPkgsTable   = "CREATE TABLE IF NOT EXISTS branch_pkgs (branch TEXT, arch TEXT, name TEXT, version TEXT, repo TEXT, builddate TIME, desc TEXT, provides TEXT, groups TEXT)"
+	branchPkgsColumns = "branch, arch, name, version, repo, builddate, desc, provides, groups"
+)
+
+/*
+ * a version of a package in pkg_history
+ * LEFT is empty if version is the current one of its branch
+ */
+type VersionHistory struct {
+	VERSION   string
+	REPO      string
+	BRANCH    string `json:",omitempty"`
+	ARCH      string `json:",omitempty"`
+	BUILDDATE string
+	FIRSTSEEN string
+	LEFT      string `json:",omitempty"`
+}
+
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+type sqlQueryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func hasTable(db sqlQueryer, schema string, table string) bool {
+	rows, err := db.Query("SELECT name FROM "+schema+".sqlite_master WHERE type='table' AND name=?", table)
+	if err != nil {
+		return false
+	}
+	defer rows.Close()
+	return rows.Next()
+}
+
+func hasColumn(db sqlQueryer, schema string, table string, column string) bool {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?, ?)", table, schema)
+	if err != nil {
+		return false
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if rows.Scan(&name) == nil && name == column {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * packages of the last sync of a branch (only NAME, VERSION, REPO, BUILDDATE)
+ */
+func loadBranchPackages(db sqlQueryer, branch string, arch string) (Packages, error) {
+	rows, err := db.Query("SELECT name, version, ifnull(repo, ''), ifnull(strftime('%s', builddate), 0) FROM branch_pkgs WHERE branch=? AND arch=?", branch, arch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := Packages{}
+	for rows.Next() {
+		p := Package{}
+		if err := rows.Scan(&p.NAME, &p.VERSION, &p.REPO, &p.BUILDDATE); err != nil {
+			return ret, err
+		}
+		ret = append(ret, p)
+	}
+	return ret, rows.Err()
+}
+
+/*
+ * branch and architecture of a sync, same for all repos
+ */
+func syncBranch(infos map[string]*RepoInfo) (branch string, arch string) {
+	for _, info := range infos {
+		if branch == "" {
+			branch = info.BRANCH
+		}
+		if arch == "" {
+			arch = info.ARCH
+		}
+	}
+	return branch, arch
+}
+
+/*
+ * architecture of pacman.db, "" for previous version without repos.arch
+ */
+func dbArch(db sqlQueryer, schema string) string {
+	if !hasColumn(db, schema, "repos", "arch") {
+		return ""
+	}
+	rows, err := db.Query("SELECT arch FROM " + schema + ".repos WHERE arch IS NOT NULL LIMIT 1")
+	if err != nil {
+		return ""
+	}
+	defer rows.Close()
+	arch := ""
+	if rows.Next() {
+		rows.Scan(&arch)
+	}
+	return arch
+}
+
+/*
+ * pacman.db of previous version, history without arch
+ */
+func migrateHistory(db sqlDb) error {
+	if hasColumn(db, "main", "pkg_history", "arch") {
+		return nil
+	}
+	if _, err := db.Exec("ALTER TABLE pkg_history ADD COLUMN arch TEXT"); err != nil {
+		return err
+	}
+	_, err := db.Exec("UPDATE pkg_history SET arch=?", dbArch(db, "main"))
+	return err
+}
+
+/*
+ * pacman.db of previous version, history without branch_pkgs:
+ * packages of pacman.db are the last sync of its branch
+ */
+func initBranchPackages(db sqlDb, schema string) error {
+	if _, err := db.Exec(branchPkgsTable); err != nil {
+		return err
+	}
+	repoArch := "NULL"
+	if hasColumn(db, schema, "repos", "arch") {
+		repoArch = "r.arch"
+	}
+	repoBranch := "NULL"
+	if hasColumn(db, schema, "repos", "branch") {
+		repoBranch = "r.branch"
+	}
+	groups := "''"
+	if hasTable(db, schema, "groups") {
+		groups = "ifnull((SELECT group_concat(groupname, ' ') FROM " + schema + ".groups g WHERE g.id=p.id), '')"
+	}
+	_, err := db.Exec("INSERT INTO branch_pkgs (" + branchPkgsColumns + ") SELECT ifnull(" + repoBranch + ", ''), ifnull(" + repoArch + ", ''), p.name, p.version, ifnull(r.repo, ''), p.builddate, ifnull(p.desc, ''), " +
+		"ifnull((SELECT group_concat(provide, ' ') FROM " + schema + ".provides v WHERE v.id=p.id), ''), " + groups +
+		" FROM " + schema + ".pkgs p LEFT JOIN " + schema + ".repos r ON r.id=p.repo")
+	return err
+}
+
+/*
+ * branch_pkgs of previous version, without search columns
+ */
+func migrateBranchPackages(db sqlDb) error {
+	if hasColumn(db, "main", "branch_pkgs", "desc") {
+		return nil
+	}
+	for _, column := range []string{"desc", "provides", "groups"} {
+		if _, err := db.Exec("ALTER TABLE branch_pkgs ADD COLUMN " + column + " TEXT"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * save version changes since the last sync of the same branch in pkg_history
+ * and replace packages of this branch in branch_pkgs
+ * first sync of a branch: all packages are added
+ */
+func saveHistory(db sqlDb, pkgs Packages, infos map[string]*RepoInfo) error {
+	branch, arch := syncBranch(infos)
+	previous, err := loadBranchPackages(db, branch, arch)
+	if err != nil {
+		return err
+	}
+	if err := insertHistory(db, DiffPackages(previous, pkgs), previous, pkgs, branch, arch); err != nil {
+		return err
+	}
+	if _, err := db.Exec("DELETE FROM branch_pkgs WHERE branch=? AND arch=?", branch, arch); err != nil {
+		return err
+	}
+	for name, pkg := range packagesByName(pkgs) {
+		_, err := db.Exec("INSERT INTO branch_pkgs ("+branchPkgsColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			branch, arch, name, pkg.VERSION, pkg.REPO, time.Unix(pkg.BUILDDATE, 0).Format("2006-01-02 15:04:05"),
+			pkg.DESC, strings.Join(pkg.PROVIDES, " "), strings.Join(pkg.GROUPS, " "))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * save version changes in pkg_history
+ * previous, current: packages of the branch before and after the sync
+ */
+func insertHistory(db sqlExecer, changes []PackageDiff, previous Packages, current Packages, branch string, arch string) error {
+	olds := packagesByName(previous)
+	news := packagesByName(current)
+	date := time.Now().Format("2006-01-02 15:04:05")
+	for _, c := range changes {
+		repo := c.NEWREPO
+		var builddate int64
+		if pkg, found := news[c.NAME]; found && c.NEWVERSION != "" {
+			builddate = pkg.BUILDDATE
+		} else if pkg, found := olds[c.NAME]; found {
+			repo = c.OLDREPO
+			builddate = pkg.BUILDDATE
+		}
+		_, err := db.Exec("INSERT INTO pkg_history (name, repo, branch, arch, oldversion, newversion, builddate, date) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			c.NAME, repo, branch, arch, c.OLDVERSION, c.NEWVERSION, time.Unix(builddate, 0).Format("2006-01-02 15:04:05"), date)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * GenSqlite: keep history and branches of previous pacman.db and add version changes
+ * previous pacman.db without history: all packages are added
+ */
+func copyHistory(db *sql.DB, dbFile string, pkgs Packages, infos map[string]*RepoInfo) error {
+	for _, table := range []string{historyTable, branchPkgsTable} {
+		if _, err := db.Exec(table); err != nil {
+			return err
+		}
+	}
+	if _, err := os.Stat(dbFile); err == nil {
+		if _, err := db.Exec("ATTACH DATABASE ? AS old", dbFile); err == nil {
+			err := copyOldHistory(db)
+			db.Exec("DETACH DATABASE old")
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return saveHistory(db, pkgs, infos)
+}
+
+func copyOldHistory(db *sql.DB) error {
+	if !hasTable(db, "old", "pkg_history") {
+		return nil
+	}
+	arch := "NULL"
+	if hasColumn(db, "old", "pkg_history", "arch") {
+		arch = "arch"
+	}
+	if _, err := db.Exec("INSERT INTO pkg_history (name, repo, branch, arch, oldversion, newversion, builddate, date) SELECT name, repo, branch, ifnull("+arch+", ?), oldversion, newversion, builddate, date FROM old.pkg_history", dbArch(db, "old")); err != nil {
+		return err
+	}
+	if !hasTable(db, "old", "branch_pkgs") {
+		return initBranchPackages(db, "old")
+	}
+	columns := "branch, arch, name, version, repo, builddate"
+	if hasColumn(db, "old", "branch_pkgs", "desc") {
+		columns = branchPkgsColumns
+	}
+	_, err := db.Exec("INSERT INTO branch_pkgs (" + columns + ") SELECT " + columns + " FROM old.branch_pkgs")
+	return err
+}
+
+/*
+ * versions of a package: when each version first appeared and when it left its branch
+ * in order of syncs, branch "": all branches
+ */
+func History(db *sql.DB, name string, branch string) ([]VersionHistory, error) {
+	rows, err := db.Query("SELECT ifnull(repo, ''), ifnull(branch, ''), ifnull(arch, ''), ifnull(oldversion, ''), ifnull(newversion, ''), ifnull(builddate, ''), ifnull(date, '') FROM pkg_history WHERE name=? AND (?='' OR branch=?) ORDER BY date, rowid", name, branch, branch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := []VersionHistory{}
+	for rows.Next() {
+		var repo, rowBranch, arch, oldVersion, newVersion, builddate, date string
+		if err := rows.Scan(&repo, &rowBranch, &arch, &oldVersion, &newVersion, &builddate, &date); err != nil {
+			return ret, err
+		}
+		if oldVersion != "" {
+			for i := len(ret) - 1; i >= 0; i-- {
+				if ret[i].VERSION == oldVersion && ret[i].BRANCH == rowBranch && ret[i].ARCH == arch && ret[i].LEFT == "" {
+					ret[i].LEFT = date
+					break
+				}
+			}
+		}
+		if newVersion != "" {
+			ret = append(ret, VersionHistory{VERSION: newVersion, REPO: repo, BRANCH: rowBranch, ARCH: arch, BUILDDATE: builddate, FIRSTSEEN: date})
+		}
+	}
+	return ret, rows.Err()
+}
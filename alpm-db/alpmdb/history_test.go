@@ -0,0 +1,99 @@
+package alpmdb
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testInfos(branch string) map[string]*RepoInfo {
+	return map[string]*RepoInfo{
+		"core": {NAME: "core", BRANCH: branch, ARCH: "x86_64"},
+	}
+}
+
+func testBranch(branch string) Packages {
+	bash := "5.3-1"
+	if branch == "testing" {
+		bash = "5.4-1"
+	}
+	return testPackages(
+		Package{NAME: "bash", VERSION: bash, REPO: "core"},
+		Package{NAME: "glibc", VERSION: "2.39-1", REPO: "core"},
+	)
+}
+
+func historyOf(t *testing.T, dbFile string, name string, branch string) []string {
+	t.Helper()
+	db, err := OpenReadOnly(dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	versions, err := History(db, name, branch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret := []string{}
+	for _, v := range versions {
+		left := ""
+		if v.LEFT != "" {
+			left = " left"
+		}
+		ret = append(ret, v.BRANCH+"/"+v.ARCH+" "+v.VERSION+left)
+	}
+	return ret
+}
+
+/*
+ * stable, testing then stable again: switching branch is not a version change
+ */
+func TestHistoryBranches(t *testing.T) {
+	for _, update := range []bool{false, true} {
+		dbFile := filepath.Join(t.TempDir(), "history.db")
+		for _, branch := range []string{"stable", "testing", "stable"} {
+			var err error
+			if update {
+				_, err = UpdateSqlite(dbFile, testBranch(branch), nil, testInfos(branch))
+			} else {
+				err = GenSqlite(dbFile, testBranch(branch), nil, testInfos(branch))
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		want := []string{"stable/x86_64 5.3-1", "testing/x86_64 5.4-1"}
+		if got := historyOf(t, dbFile, "bash", ""); !reflect.DeepEqual(got, want) {
+			t.Errorf("update %v: history of bash = %v, want %v", update, got, want)
+		}
+		want = []string{"testing/x86_64 5.4-1"}
+		if got := historyOf(t, dbFile, "bash", "testing"); !reflect.DeepEqual(got, want) {
+			t.Errorf("update %v: history of bash in testing = %v, want %v", update, got, want)
+		}
+	}
+}
+
+/*
+ * upgrade and removal in the same branch
+ */
+func TestHistoryVersions(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "versions.db")
+	syncs := []Packages{
+		testPackages(Package{NAME: "bash", VERSION: "5.2-1", REPO: "core"}, Package{NAME: "old", VERSION: "1.0-1", REPO: "core"}),
+		testPackages(Package{NAME: "bash", VERSION: "5.3-1", REPO: "core"}),
+	}
+	for _, pkgs := range syncs {
+		if _, err := UpdateSqlite(dbFile, pkgs, nil, testInfos("stable")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"stable/x86_64 5.2-1 left", "stable/x86_64 5.3-1"}
+	if got := historyOf(t, dbFile, "bash", "stable"); !reflect.DeepEqual(got, want) {
+		t.Errorf("history of bash = %v, want %v", got, want)
+	}
+	want = []string{"stable/x86_64 1.0-1 left"}
+	if got := historyOf(t, dbFile, "old", ""); !reflect.DeepEqual(got, want) {
+		t.Errorf("history of old = %v, want %v", got, want)
+	}
+}
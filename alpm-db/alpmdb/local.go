@@ -0,0 +1,82 @@
+package alpmdb
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+/*
+ * installed packages: /var/lib/pacman/local/<name>-<version>/desc
+ */
+func LoadLocalDb(localDb string) (Packages, error) {
+	var pkgs Packages
+	dirs, err := os.ReadDir(localDb)
+	if err != nil {
+		return nil, fmt.Errorf("LoadLocalDb: %s", err.Error())
+	}
+	for _, dir := range dirs {
+		// ALPM_DB_VERSION file
+		if !dir.IsDir() {
+			continue
+		}
+		content, err := os.ReadFile(localDb + "/" + dir.Name() + "/desc")
+		if err != nil {
+			logln("LoadLocalDb:", err)
+			continue
+		}
+		pkg := Package{
+			dir:  dir.Name() + "/desc",
+			REPO: "local",
+			id:   int32(len(pkgs)) + 1,
+		}
+		if pkg.Set(string(content)) {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs, nil
+}
+
+const installedTable = "CREATE TABLE IF NOT EXISTS installed (id INTEGER PRIMARY KEY, name TEXT UNIQUE NOT NULL, base TEXT DEFAULT NULL, version TEXT NOT NULL, desc TEXT, url TEXT, builddate TIME, installdate TIME, reason INTEGER, validation TEXT, size INTEGER, packager TEXT, pkg INTEGER DEFAULT NULL)"
+
+/*
+ * installed table
+ * pkg: package id in repos (pkgs.id), NULL if not in sync repos
+ */
+func genSqliteInstalled(db *sql.DB, installed Packages, pkgs Packages) error {
+	if _, err := db.Exec(installedTable); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := insertInstalled(tx, installed, pkgs); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertInstalled(tx *sql.Tx, installed Packages, pkgs Packages) error {
+	stmt, err := tx.Prepare("INSERT or IGNORE INTO installed (id, name, base, version, desc, url, builddate, installdate, reason, validation, size, packager, pkg) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, pkg := range installed {
+		_, err := stmt.Exec(
+			pkg.id, pkg.NAME, pkg.getBase(), pkg.VERSION, pkg.DESC, pkg.URL,
+			time.Unix(pkg.BUILDDATE, 0).Format("2006-01-02 15:04:05"),
+			time.Unix(pkg.INSTALLDATE, 0).Format("2006-01-02 15:04:05"),
+			pkg.REASON, strings.Join(pkg.VALIDATION, ","), pkg.SIZE, pkg.PACKAGER,
+			pkgs.FindByName(pkg.NAME))
+		if err != nil {
+			return fmt.Errorf("installed insert %s: %s", pkg.NAME, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package alpmdb
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+type tdesc map[string][]string
+
+type Package struct {
+	FILENAME     string
+	dir          string
+	id           int32
+	NAME         string
+	BASE         string `json:",omitempty"`
+	VERSION      string
+	DESC         string
+	REPO         string
+	URL          string   `json:",omitempty"`
+	LICENSE      []string `json:",omitempty"`
+	GROUPS       []string `json:",omitempty"`
+	ARCH         string
+	PACKAGER     string
+	PROVIDES     []string `json:",omitempty"`
+	CONFLICTS    []string `json:",omitempty"`
+	REPLACES     []string `json:",omitempty"`
+	DEPENDS      []string `json:",omitempty"`
+	OPTDEPENDS   []string `json:",omitempty"`
+	MAKEDEPENDS  []string `json:",omitempty"`
+	CHECKDEPENDS []string `json:",omitempty"`
+	FILES        []string `json:",omitempty"` // only in .files db
+	BUILDDATE    int64
+	ISIZE        int
+	CSIZE        int
+	MD5SUM       string   `json:",omitempty"`
+	SHA256SUM    string   `json:",omitempty"`
+	PGPSIG       string   `json:",omitempty"`
+	XDATA        []string `json:",omitempty"` // key=value
+	// only in local db (installed packages)
+	INSTALLDATE int64    `json:",omitempty"`
+	REASON      int      `json:",omitempty"` // 0: explicit, 1: dependency
+	VALIDATION  []string `json:",omitempty"`
+	SIZE        int      `json:",omitempty"`
+}
+
+func getFieldString(adesc tdesc, key string) string {
+	if len(adesc[key]) < 1 {
+		return ""
+	}
+	return strings.TrimSpace(adesc[key][0])
+}
+
+func getFieldArray(adesc tdesc, key string) []string {
+	if len(adesc[key]) < 1 {
+		return make([]string, 0)
+	}
+	//TOFIX last field in linux-lts removed ???
+	for k, v := range adesc[key] { // remove descriptions
+		adesc[key][k] = strings.TrimSpace(strings.SplitN(v, ":", 2)[0])
+	}
+	return adesc[key][0:]
+}
+
+func getFieldInt(adesc tdesc, key string) int {
+	if items, ok := adesc[key]; ok {
+		item := items[0]
+		i, err := strconv.Atoi(item)
+		if err == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+/*
+ * split desc content in fields: %NAME%\nvalue\n\n%FIELD%\nvalue\nvalue...
+ */
+func parseDesc(desc string) tdesc {
+	tmpdesc := strings.Split(desc, "\n\n")
+	adesc := make(tdesc)
+	for i := range tmpdesc {
+		tmp := strings.Split(tmpdesc[i], "\n")
+		idx := strings.Replace(tmp[0], "%", "", -1)
+		if len(tmp) > 1 {
+			adesc[idx] = tmp[1:]
+		} else {
+			adesc[idx] = make([]string, 0)
+		}
+	}
+	return adesc
+}
+
+// parse desc file content
+func (p *Package) Set(desc string) bool {
+	//fields := []string{"FILENAME", "NAME", "VERSION", "URL", "DESC", "BASE"}
+
+	/*if (p.dir == "stratis-cli-1.0.2-1/desc") {
+		fmt.Println("stratis-cli desc origin:",desc)
+	}*/
+	adesc := parseDesc(desc)
+	/*for k,v := range adesc {
+		fmt.Println(k, "->", v)
+	}*/
+
+	p.VERSION = getFieldString(adesc, "VERSION")
+	p.NAME = getFieldString(adesc, "NAME")
+	/*if (p.NAME == "stratis-cli") {
+		fmt.Println("stratis-cli adesc:",adesc)
+		fmt.Println("stratis-cli:",p)
+		//os.Exit(1)
+	}*/
+	p.DESC = getFieldString(adesc, "DESC")
+	p.URL = getFieldString(adesc, "URL")
+	p.BASE = getFieldString(adesc, "BASE")
+	if p.BASE == p.NAME {
+		p.BASE = ""
+	}
+	p.PACKAGER = getFieldString(adesc, "PACKAGER")
+	p.ARCH = getFieldString(adesc, "ARCH")
+	p.FILENAME = getFieldString(adesc, "FILENAME")
+
+	p.LICENSE = getFieldArray(adesc, "LICENSE")
+	p.DEPENDS = getFieldArray(adesc, "DEPENDS")
+	p.MAKEDEPENDS = getFieldArray(adesc, "MAKEDEPENDS")
+	p.OPTDEPENDS = getFieldArray(adesc, "OPTDEPENDS")
+	p.PROVIDES = getFieldArray(adesc, "PROVIDES")
+	p.CONFLICTS = getFieldArray(adesc, "CONFLICTS")
+	p.REPLACES = getFieldArray(adesc, "REPLACES")
+	p.GROUPS = getFieldArray(adesc, "GROUPS")
+	p.CHECKDEPENDS = getFieldArray(adesc, "CHECKDEPENDS")
+	p.XDATA = getFieldArray(adesc, "XDATA")
+
+	p.MD5SUM = getFieldString(adesc, "MD5SUM")
+	p.SHA256SUM = getFieldString(adesc, "SHA256SUM")
+	p.PGPSIG = getFieldString(adesc, "PGPSIG")
+
+	p.BUILDDATE = int64(getFieldInt(adesc, "BUILDDATE"))
+	p.CSIZE = getFieldInt(adesc, "CSIZE")
+	p.ISIZE = getFieldInt(adesc, "ISIZE")
+
+	if _, found := adesc["INSTALLDATE"]; found {
+		p.INSTALLDATE = int64(getFieldInt(adesc, "INSTALLDATE"))
+		p.SIZE = getFieldInt(adesc, "SIZE")
+		p.VALIDATION = getFieldArray(adesc, "VALIDATION")
+		// not in desc if explicit
+		if p.REASON = getFieldInt(adesc, "REASON"); p.REASON < 0 {
+			p.REASON = 0
+		}
+	}
+
+	//fmt.Println("\n--- package struct --- \n",p,"\n---")
+	return true
+}
+
+// parse files file content (.files db)
+func (p *Package) SetFiles(content string) {
+	adesc := parseDesc(content)
+	p.FILES = make([]string, 0, len(adesc["FILES"]))
+	for _, file := range adesc["FILES"] {
+		// not getFieldArray(): ":" is valid in a path
+		if file = strings.TrimSpace(file); file != "" {
+			p.FILES = append(p.FILES, file)
+		}
+	}
+}
+
+/*
+ * id in pacman.db (pkgs.id)
+ */
+func (p *Package) ID() int32 {
+	return p.id
+}
+
+/*
+ * hash of package content (desc and files), pkgs.hash used to detect changes
+ */
+func (p *Package) checksum() string {
+	content, _ := json.Marshal(p)
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func (p *Package) getBase() sql.NullString {
+	if len(p.BASE) > 0 && p.BASE != p.NAME {
+		return sql.NullString{
+			String: p.BASE,
+			Valid:  true,
+		}
+	}
+	return sql.NullString{}
+}
+
+type Packages []Package
+
+/*
+ * find pakage by name
+ * for replace sql too long replace package name field by field id
+ * version only sql, gen : 37 seconds
+ * version with FindByName() : 11 seconds !
+ */
+func (p *Packages) FindByName(name string) sql.NullInt32 {
+	if strings.Contains(name, ".so") {
+		return sql.NullInt32{}
+	}
+	for _, pkg := range *p {
+		if pkg.NAME == name {
+			return sql.NullInt32{
+				Int32: int32(pkg.id),
+				Valid: true,
+			}
+		}
+	}
+	return sql.NullInt32{}
+}
+
+type PackageFilter map[string]bool
+
+/*
+ * packages for this architecture or "any", count of removed packages
+ */
+func (pkgs Packages) FilterArch(arch string) (Packages, int) {
+	ret := pkgs[:0]
+	for _, pkg := range pkgs {
+		if pkg.ARCH == "" || pkg.ARCH == "any" || pkg.ARCH == arch {
+			ret = append(ret, pkg)
+		}
+	}
+	return ret, len(pkgs) - len(ret)
+}
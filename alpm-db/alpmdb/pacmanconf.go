@@ -0,0 +1,197 @@
+package alpmdb
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const PacmanConfFile = "/etc/pacman.conf"
+
+// architecture of mirrors without --arch
+const DefaultArch = "x86_64"
+
+/*
+ * one [repo] section of pacman.conf
+ * Servers: as in pacman.conf, $repo and $arch are replaced by PacmanConf.Servers()
+ */
+type PacmanRepo struct {
+	Name     string
+	Servers  []string
+	SigLevel []string
+}
+
+type PacmanConf struct {
+	Architecture string
+	SigLevel     []string
+	Repos        []PacmanRepo // in pacman order
+}
+
+/*
+ * pacman "Architecture = auto"
+ */
+func runtimeArch() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x86_64"
+	case "arm64":
+		return "aarch64"
+	case "386":
+		return "i686"
+	case "arm":
+		return "armv7h"
+	}
+	return runtime.GOARCH
+}
+
+func substituteServer(server string, repo string, arch string) string {
+	server = strings.Replace(server, "$repo", repo, -1)
+	return strings.Replace(server, "$arch", arch, -1)
+}
+
+/*
+ * parse pacman.conf and included files (mirrorlist)
+ */
+func ParsePacmanConf(filename string) (*PacmanConf, error) {
+	conf := PacmanConf{}
+	section := ""
+	if err := conf.parse(filename, &section); err != nil {
+		return nil, err
+	}
+	if conf.Architecture == "" || conf.Architecture == "auto" {
+		conf.Architecture = runtimeArch()
+	}
+	return &conf, nil
+}
+
+/*
+ * section is shared with included files
+ */
+func (conf *PacmanConf) parse(filename string, section *string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.SplitN(scanner.Text(), "#", 2)[0])
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			*section = strings.TrimSpace(line[1 : len(line)-1])
+			if *section != "options" {
+				conf.Repos = append(conf.Repos, PacmanRepo{Name: *section})
+			}
+			continue
+		}
+		tmp := strings.SplitN(line, "=", 2)
+		if len(tmp) < 2 {
+			// options without value (Color, CheckSpace...)
+			continue
+		}
+		key := strings.TrimSpace(tmp[0])
+		value := strings.TrimSpace(tmp[1])
+
+		if key == "Include" {
+			files, err := filepath.Glob(value)
+			if err != nil {
+				return fmt.Errorf("%s: Include %s: %s", filename, value, err)
+			}
+			if len(files) < 1 {
+				return fmt.Errorf("%s: Include %s: file not found", filename, value)
+			}
+			for _, include := range files {
+				if err := conf.parse(include, section); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if *section == "options" {
+			switch key {
+			case "Architecture":
+				// "auto" or empty: runtime architecture
+				if fields := strings.Fields(value); len(fields) > 0 {
+					conf.Architecture = fields[0]
+				}
+			case "SigLevel":
+				conf.SigLevel = strings.Fields(value)
+			}
+			continue
+		}
+		if len(conf.Repos) < 1 {
+			continue
+		}
+		repo := &conf.Repos[len(conf.Repos)-1]
+		switch key {
+		case "Server":
+			repo.Servers = append(repo.Servers, value)
+		case "SigLevel":
+			repo.SigLevel = strings.Fields(value)
+		}
+	}
+	return scanner.Err()
+}
+
+/*
+ * repos names in pacman order
+ */
+func (conf *PacmanConf) RepoNames() []string {
+	ret := make([]string, 0, len(conf.Repos))
+	for _, repo := range conf.Repos {
+		ret = append(ret, repo.Name)
+	}
+	return ret
+}
+
+/*
+ * repo -> servers for conf.Architecture
+ */
+func (conf *PacmanConf) Servers() map[string][]string {
+	ret := make(map[string][]string, len(conf.Repos))
+	for _, repo := range conf.Repos {
+		for _, server := range repo.Servers {
+			ret[repo.Name] = append(ret[repo.Name], substituteServer(server, repo.Name, conf.Architecture))
+		}
+	}
+	return ret
+}
+
+/*
+ * database part of a SigLevel: Never, Optional or Required
+ * "Required" is for packages and databases, "DatabaseRequired" only for databases
+ */
+func dbSigLevel(siglevel []string, def string) string {
+	ret := def
+	for _, item := range siglevel {
+		switch item {
+		case "Never", "DatabaseNever":
+			ret = SIGLEVEL_NEVER
+		case "Optional", "DatabaseOptional":
+			ret = SIGLEVEL_OPTIONAL
+		case "Required", "DatabaseRequired":
+			ret = SIGLEVEL_REQUIRED
+		}
+	}
+	return ret
+}
+
+/*
+ * repo -> database SigLevel
+ * pacman default: "Required DatabaseOptional"
+ */
+func (conf *PacmanConf) DbSigLevels() map[string]string {
+	def := dbSigLevel(conf.SigLevel, SIGLEVEL_OPTIONAL)
+	ret := make(map[string]string, len(conf.Repos))
+	for _, repo := range conf.Repos {
+		ret[repo.Name] = dbSigLevel(repo.SigLevel, def)
+	}
+	return ret
+}
@@ -0,0 +1,322 @@
+package alpmdb
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+/*
+ * a dependency of a package and the package selected as pacman (pacman.db)
+ * NAME is empty if dependency is not satisfied
+ */
+type Dependency struct {
+	TYPE    string // depends, makedepends, optdepends
+	DEPEND  string // as declared: name[comp version]
+	NAME    string `json:",omitempty"`
+	VERSION string `json:",omitempty"`
+	REPO    string `json:",omitempty"`
+}
+
+func queryStrings(db *sql.DB, request string, args ...interface{}) ([]string, error) {
+	rows, err := db.Query(request, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := []string{}
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return ret, err
+		}
+		ret = append(ret, value)
+	}
+	return ret, rows.Err()
+}
+
+/*
+ * a package from pacman.db with all its relations
+ * return nil if package not found
+ */
+func GetPackage(db *sql.DB, name string) (*Package, error) {
+	p := Package{}
+	var base, repo, url, packager sql.NullString
+	var builddate sql.NullInt64
+	err := db.QueryRow("SELECT pkgs.id, pkgs.name, pkgs.base, pkgs.version, repos.repo, pkgs.desc, pkgs.url, strftime('%s', pkgs.builddate), pkgs.csize, pkgs.isize, packagers.packager "+
+		"FROM pkgs LEFT JOIN repos ON repos.id=pkgs.repo LEFT JOIN packagers ON packagers.id=pkgs.packager WHERE pkgs.name=?", name).Scan(
+		&p.id, &p.NAME, &base, &p.VERSION, &repo, &p.DESC, &url, &builddate, &p.CSIZE, &p.ISIZE, &packager)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.BASE, p.REPO, p.URL, p.PACKAGER, p.BUILDDATE = base.String, repo.String, url.String, packager.String, builddate.Int64
+
+	relations := []struct {
+		field   *[]string
+		request string
+	}{
+		{&p.LICENSE, "SELECT licence FROM licences WHERE id=?"},
+		{&p.PROVIDES, "SELECT provide||comp||ver FROM provides WHERE id=?"},
+		{&p.CONFLICTS, "SELECT conflict||comp||ver FROM conflicts WHERE id=?"},
+		{&p.DEPENDS, "SELECT depend||comp||ver FROM depends WHERE id=?"},
+		{&p.OPTDEPENDS, "SELECT optdepend FROM optdepends WHERE id=?"},
+		{&p.MAKEDEPENDS, "SELECT depend||comp||ver FROM makedepends WHERE id=?"},
+	}
+	// pacman.db of previous version: without desc tables
+	withDesc := hasTable(db, "main", "checksums")
+	if withDesc {
+		relations = append(relations, []struct {
+			field   *[]string
+			request string
+		}{
+			{&p.GROUPS, "SELECT groupname FROM groups WHERE id=?"},
+			{&p.REPLACES, "SELECT replace||comp||ver FROM replaces WHERE id=?"},
+			{&p.CHECKDEPENDS, "SELECT depend||comp||ver FROM checkdepends WHERE id=?"},
+			{&p.XDATA, "SELECT key||'='||value FROM xdata WHERE id=?"},
+		}...)
+	}
+	for _, relation := range relations {
+		if *relation.field, err = queryStrings(db, relation.request, p.id); err != nil {
+			return &p, err
+		}
+	}
+	if withDesc {
+		err = db.QueryRow("SELECT ifnull(md5sum, ''), ifnull(sha256sum, ''), ifnull(pgpsig, '') FROM checksums WHERE id=?", p.id).Scan(&p.MD5SUM, &p.SHA256SUM, &p.PGPSIG)
+		if err != nil && err != sql.ErrNoRows {
+			return &p, err
+		}
+	}
+	return &p, nil
+}
+
+/*
+ * dependencies of a package, resolved with field pkg filled by GenSqlite
+ * return nil if package not found
+ */
+func Depends(db *sql.DB, name string, withMake bool, withOpt bool) ([]Dependency, error) {
+	var id int32
+	err := db.QueryRow("SELECT id FROM pkgs WHERE name=?", name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []Dependency{}
+	for _, table := range []string{"depends", "makedepends", "optdepends"} {
+		depend := table + ".depend||" + table + ".comp||" + table + ".ver"
+		switch {
+		case table == "makedepends" && !withMake:
+			continue
+		case table == "optdepends":
+			if !withOpt {
+				continue
+			}
+			depend = "optdepends.optdepend"
+		}
+		rows, err := db.Query("SELECT "+depend+", pkgs.name, pkgs.version, repos.repo FROM "+table+
+			" LEFT JOIN pkgs ON pkgs.id="+table+".pkg LEFT JOIN repos ON repos.id=pkgs.repo WHERE "+table+".id=? ORDER BY "+depend, id)
+		if err != nil {
+			return ret, err
+		}
+		for rows.Next() {
+			d := Dependency{TYPE: table}
+			var name, version, repo sql.NullString
+			if err := rows.Scan(&d.DEPEND, &name, &version, &repo); err != nil {
+				rows.Close()
+				return ret, err
+			}
+			d.NAME, d.VERSION, d.REPO = name.String, version.String, repo.String
+			ret = append(ret, d)
+		}
+		rows.Close()
+	}
+	return ret, nil
+}
+
+// sort fields of ListPackages
+var listSorts = map[string]string{
+	"name":      "pkgs.name",
+	"version":   "pkgs.version COLLATE vercmp", // "10.0" after "9.0"
+	"repo":      "pkgs.repo",
+	"builddate": "pkgs.builddate",
+	"csize":     "pkgs.csize",
+	"isize":     "pkgs.isize",
+}
+
+/*
+ * filters, order and page of ListPackages
+ * SEARCH: text in name or description, SORT: name, version, repo, builddate, csize, isize
+ */
+type ListOptions struct {
+	SEARCH string
+	REPO   string
+	SORT   string
+	DESC   bool
+	OFFSET int
+	LIMIT  int // 0: all
+}
+
+/*
+ * packages of pacman.db, without relations
+ * return also count of packages without OFFSET and LIMIT
+ */
+func ListPackages(db *sql.DB, options ListOptions) (Packages, int, error) {
+	order, found := listSorts[options.SORT]
+	if options.SORT == "" {
+		order = listSorts["name"]
+	} else if !found {
+		return nil, 0, fmt.Errorf("invalid sort: %s", options.SORT)
+	}
+	if options.DESC {
+		order += " DESC"
+	}
+
+	where := "1=1"
+	args := []interface{}{}
+	if options.SEARCH != "" {
+		where += " AND (pkgs.name LIKE ? OR pkgs.desc LIKE ?)"
+		args = append(args, "%"+options.SEARCH+"%", "%"+options.SEARCH+"%")
+	}
+	if options.REPO != "" {
+		where += " AND repos.repo=?"
+		args = append(args, options.REPO)
+	}
+	from := " FROM pkgs LEFT JOIN repos ON repos.id=pkgs.repo LEFT JOIN packagers ON packagers.id=pkgs.packager WHERE " + where
+
+	var total int
+	if err := db.QueryRow("SELECT count(pkgs.id)"+from, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	limit := ""
+	if options.LIMIT > 0 {
+		limit = fmt.Sprintf(" LIMIT %d OFFSET %d", options.LIMIT, options.OFFSET)
+	}
+	rows, err := db.Query("SELECT pkgs.id, pkgs.name, pkgs.base, pkgs.version, repos.repo, pkgs.desc, pkgs.url, strftime('%s', pkgs.builddate), pkgs.csize, pkgs.isize, packagers.packager"+
+		from+" ORDER BY "+order+", pkgs.name"+limit, args...)
+	if err != nil {
+		return nil, total, err
+	}
+	defer rows.Close()
+
+	ret := Packages{}
+	for rows.Next() {
+		p := Package{}
+		var base, repo, desc, url, packager sql.NullString
+		var builddate sql.NullInt64
+		if err := rows.Scan(&p.id, &p.NAME, &base, &p.VERSION, &repo, &desc, &url, &builddate, &p.CSIZE, &p.ISIZE, &packager); err != nil {
+			return ret, total, err
+		}
+		p.BASE, p.REPO, p.DESC, p.URL, p.PACKAGER, p.BUILDDATE = base.String, repo.String, desc.String, url.String, packager.String, builddate.Int64
+		ret = append(ret, p)
+	}
+	return ret, total, rows.Err()
+}
+
+/*
+ * repos of pacman.db with sync state
+ */
+func Repos(db *sql.DB) ([]RepoInfo, error) {
+	rows, err := db.Query("SELECT repo, ifnull(url, ''), ifnull(branch, ''), ifnull(arch, ''), ifnull(siglevel, ''), ifnull(sigstatus, ''), ifnull(sigkey, '') FROM repos ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := []RepoInfo{}
+	for rows.Next() {
+		r := RepoInfo{}
+		if err := rows.Scan(&r.NAME, &r.URL, &r.BRANCH, &r.ARCH, &r.SIGLEVEL, &r.SIGSTATUS, &r.SIGKEY); err != nil {
+			return ret, err
+		}
+		ret = append(ret, r)
+	}
+	return ret, rows.Err()
+}
+
+/*
+ * a packager and count of its packages
+ */
+type Packager struct {
+	NAME     string
+	PACKAGES int
+}
+
+func Packagers(db *sql.DB) ([]Packager, error) {
+	rows, err := db.Query("SELECT packagers.packager, count(pkgs.id) AS nb FROM packagers INNER JOIN pkgs ON pkgs.packager=packagers.id GROUP BY packagers.id ORDER BY nb DESC, packagers.packager")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := []Packager{}
+	for rows.Next() {
+		p := Packager{}
+		if err := rows.Scan(&p.NAME, &p.PACKAGES); err != nil {
+			return ret, err
+		}
+		ret = append(ret, p)
+	}
+	return ret, rows.Err()
+}
+
+/*
+ * a group and count of its packages
+ */
+type Group struct {
+	NAME     string
+	PACKAGES int
+}
+
+var errNoGroups = fmt.Errorf("no groups in pacman.db, run: alpm-db sync")
+
+func Groups(db *sql.DB) ([]Group, error) {
+	if !hasTable(db, "main", "groups") {
+		return nil, errNoGroups
+	}
+	rows, err := db.Query("SELECT groupname, count(DISTINCT id) FROM groups WHERE id IN (SELECT id FROM pkgs) GROUP BY groupname ORDER BY groupname")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := []Group{}
+	for rows.Next() {
+		g := Group{}
+		if err := rows.Scan(&g.NAME, &g.PACKAGES); err != nil {
+			return ret, err
+		}
+		ret = append(ret, g)
+	}
+	return ret, rows.Err()
+}
+
+/*
+ * packages of a group, in repos order
+ * only NAME, VERSION, REPO and DESC are set
+ */
+func GroupPackages(db *sql.DB, name string) (Packages, error) {
+	if !hasTable(db, "main", "groups") {
+		return nil, errNoGroups
+	}
+	rows, err := db.Query("SELECT pkgs.name, pkgs.version, ifnull(repos.repo, ''), ifnull(pkgs.desc, '') FROM groups "+
+		"INNER JOIN pkgs ON pkgs.id=groups.id LEFT JOIN repos ON repos.id=pkgs.repo WHERE groups.groupname=? GROUP BY pkgs.id ORDER BY repos.id, pkgs.name", name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := Packages{}
+	for rows.Next() {
+		p := Package{}
+		if err := rows.Scan(&p.NAME, &p.VERSION, &p.REPO, &p.DESC); err != nil {
+			return ret, err
+		}
+		ret = append(ret, p)
+	}
+	return ret, rows.Err()
+}
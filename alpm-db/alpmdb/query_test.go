@@ -0,0 +1,43 @@
+package alpmdb
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestListPackagesSortVersion(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "list.db")
+	pkgs := testPackages(
+		Package{NAME: "a", VERSION: "9.0-1", REPO: "core"},
+		Package{NAME: "b", VERSION: "10.0-1", REPO: "core"},
+		Package{NAME: "c", VERSION: "1:1.0-1", REPO: "core"},
+		Package{NAME: "d", VERSION: "9.0rc1-1", REPO: "core"},
+	)
+	if err := GenSqlite(dbFile, pkgs, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenReadOnly(dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, desc := range []bool{false, true} {
+		list, _, err := ListPackages(db, ListOptions{SORT: "version", DESC: desc})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, pkg := range list {
+			got = append(got, pkg.VERSION)
+		}
+		want := []string{"9.0rc1-1", "9.0-1", "10.0-1", "1:1.0-1"}
+		if desc {
+			want = []string{"1:1.0-1", "10.0-1", "9.0-1", "9.0rc1-1"}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("sort version desc=%v: %v, want %v", desc, got, want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package alpmdb
+
+import (
+	"database/sql"
+	"strings"
+)
+
+type provide struct {
+	id      int32
+	version string
+}
+
+/*
+ * resolve dependencies as pacman :
+ * first a package with this name, then packages with this name in PROVIDES
+ * packages are in repos order, so first candidate is the preferred one
+ */
+type Resolver struct {
+	names    map[string]provide
+	provides map[string][]provide
+}
+
+func NewResolver(pkgs Packages) *Resolver {
+	r := Resolver{
+		names:    make(map[string]provide, len(pkgs)),
+		provides: make(map[string][]provide),
+	}
+	for _, pkg := range pkgs {
+		if _, found := r.names[pkg.NAME]; found {
+			// as pacman, ignore same package in next repos
+			continue
+		}
+		r.names[pkg.NAME] = provide{id: pkg.id, version: pkg.VERSION}
+		for _, prov := range pkg.PROVIDES {
+			name, _, ver := splitDepend(prov)
+			r.provides[name] = append(r.provides[name], provide{id: pkg.id, version: ver})
+		}
+	}
+	return &r
+}
+
+/*
+ * return all packages ids can satisfy depend ("glibc>=2.38", "sh", "libfoo.so=1-64")
+ * sonames are resolved only by provides
+ * first is the preferred
+ */
+func (r *Resolver) Resolve(depend string) []int32 {
+	name, comp, ver := splitDepend(depend)
+	ret := []int32{}
+	if pkg, found := r.names[name]; found && !isSoname(name) && VersionMatch(pkg.version, comp, ver) {
+		ret = append(ret, pkg.id)
+	}
+	match := VersionMatch
+	if isSoname(name) {
+		// libfoo.so=1-64: same ABI, compare only so version
+		match = sonameMatch
+	}
+	for _, prov := range r.provides[name] {
+		if len(ret) > 0 && ret[0] == prov.id {
+			continue
+		}
+		if prov.version == "" {
+			// provide without version satisfies only depend without version
+			if comp == "" {
+				ret = append(ret, prov.id)
+			}
+			continue
+		}
+		if match(prov.version, comp, ver) {
+			ret = append(ret, prov.id)
+		}
+	}
+	return ret
+}
+
+/*
+ * preferred package for sql field depends.pkg
+ */
+func (r *Resolver) Preferred(depend string) sql.NullInt32 {
+	ids := r.Resolve(depend)
+	if len(ids) < 1 {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{
+		Int32: ids[0],
+		Valid: true,
+	}
+}
+
+/*
+ * package with this name, as Packages.FindByName()
+ */
+func (r *Resolver) byName(name string) sql.NullInt32 {
+	pkg, found := r.names[name]
+	if !found || strings.Contains(name, ".so") {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{
+		Int32: pkg.id,
+		Valid: true,
+	}
+}
@@ -0,0 +1,109 @@
+package alpmdb
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+)
+
+const (
+	PacmanKeyring = "/etc/pacman.d/gnupg/pubring.gpg"
+
+	SIGLEVEL_NEVER    = "Never"
+	SIGLEVEL_OPTIONAL = "Optional"
+	SIGLEVEL_REQUIRED = "Required"
+
+	SIG_DISABLED    = "disabled" // not verified
+	SIG_VALID       = "valid"
+	SIG_UNSIGNED    = "unsigned" // no .sig file
+	SIG_UNKNOWN_KEY = "unknown key"
+	SIG_BAD         = "bad"
+)
+
+/*
+ * sync state of a repo, saved in table repos
+ */
+type RepoInfo struct {
+	NAME      string
+	URL       string // mirror used
+	BRANCH    string // manjaro branch, empty with pacman.conf or mirrorlist
+	ARCH      string // x86_64, aarch64...
+	SIGLEVEL  string
+	SIGSTATUS string
+	SIGKEY    string
+}
+
+/*
+ * infos in order of names: same repos ids for same input
+ */
+func sortedRepoInfos(infos map[string]*RepoInfo) []*RepoInfo {
+	ret := make([]*RepoInfo, 0, len(infos))
+	for _, info := range infos {
+		ret = append(ret, info)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].NAME < ret[j].NAME
+	})
+	return ret
+}
+
+/*
+ * keyring: binary (gpg --export, pacman pubring.gpg) or armored
+ */
+func LoadKeyring(filename string) (openpgp.EntityList, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("-----BEGIN")) {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(content))
+	}
+	return openpgp.ReadKeyRing(bytes.NewReader(content))
+}
+
+/*
+ * check detached signature dbFile.sig
+ * return status and signer (fingerprint and first identity)
+ */
+func VerifyDb(dbFile string, keyring openpgp.EntityList) (string, string) {
+	sig, err := os.Open(dbFile + ".sig")
+	if err != nil {
+		return SIG_UNSIGNED, ""
+	}
+	defer sig.Close()
+	db, err := os.Open(dbFile)
+	if err != nil {
+		return SIG_BAD, ""
+	}
+	defer db.Close()
+
+	signer, err := openpgp.CheckDetachedSignature(keyring, db, sig, nil)
+	if err == pgperrors.ErrUnknownIssuer {
+		return SIG_UNKNOWN_KEY, ""
+	}
+	if err != nil || signer == nil {
+		return SIG_BAD, ""
+	}
+	key := fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint)
+	if identity := signer.PrimaryIdentity(); identity != nil {
+		key += " " + identity.Name
+	}
+	return SIG_VALID, key
+}
+
+/*
+ * db must be rejected ?
+ */
+func SigRejected(level string, status string) bool {
+	switch level {
+	case SIGLEVEL_REQUIRED:
+		return status != SIG_VALID
+	case SIGLEVEL_OPTIONAL:
+		return status != SIG_VALID && status != SIG_UNSIGNED
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package alpmdb
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+/*
+ * library in PROVIDES (libssl.so=3-64): NAME libssl.so, VERSION 3, ABI 64
+ */
+type Soname struct {
+	NAME    string
+	VERSION string `json:",omitempty"`
+	ABI     string `json:",omitempty"`
+}
+
+func isSoname(name string) bool {
+	return strings.HasSuffix(name, ".so") || strings.Contains(name, ".so.")
+}
+
+/*
+ * "3-64" -> "3", "64"
+ */
+func splitAbi(ver string) (string, string) {
+	i := strings.LastIndex(ver, "-")
+	if i < 0 {
+		return ver, ""
+	}
+	return ver[:i], ver[i+1:]
+}
+
+func parseSoname(provide string) (Soname, bool) {
+	name, _, ver := splitDepend(provide)
+	if !isSoname(name) {
+		return Soname{}, false
+	}
+	version, abi := splitAbi(ver)
+	return Soname{NAME: name, VERSION: version, ABI: abi}, true
+}
+
+/*
+ * a soname depend is satisfied by a provide with same ABI and a matching version
+ */
+func sonameMatch(provided string, comp string, ver string) bool {
+	if comp == "" || ver == "" {
+		return true
+	}
+	version, abi := splitAbi(provided)
+	wantVersion, wantAbi := splitAbi(ver)
+	if wantAbi != "" && abi != wantAbi {
+		return false
+	}
+	return VersionMatch(version, comp, wantVersion)
+}
+
+var errNoSonames = fmt.Errorf("no sonames in pacman.db, run: alpm-db sync")
+
+/*
+ * a package with the soname in PROVIDES
+ */
+type SonameProvider struct {
+	NAME      string
+	VERSION   string
+	REPO      string
+	SOVERSION string `json:",omitempty"`
+	ABI       string `json:",omitempty"`
+}
+
+/*
+ * a package with the soname in a dependency
+ * PROVIDER: package selected for the depend, empty if not satisfied
+ */
+type SonameConsumer struct {
+	NAME     string
+	VERSION  string
+	REPO     string
+	TYPE     string // depends, makedepends, checkdepends, optdepends
+	DEPEND   string
+	PROVIDER string `json:",omitempty"`
+}
+
+type SonameIndex struct {
+	SONAME    string
+	PROVIDERS []SonameProvider
+	CONSUMERS []SonameConsumer
+}
+
+/*
+ * providers of a soname and packages that depend on it
+ * lib: libssl, libssl.so or libssl.so=3-64 (only providers of this version)
+ */
+func SonameInfo(db *sql.DB, lib string) (*SonameIndex, error) {
+	if !hasTable(db, "main", "sonames") {
+		return nil, errNoSonames
+	}
+	name, comp, ver := splitDepend(lib)
+	if !isSoname(name) {
+		name += ".so"
+	}
+	ret := SonameIndex{SONAME: name, PROVIDERS: []SonameProvider{}, CONSUMERS: []SonameConsumer{}}
+
+	rows, err := db.Query("SELECT pkgs.name, pkgs.version, ifnull(repos.repo, ''), sonames.version, sonames.abi FROM sonames "+
+		"INNER JOIN pkgs ON pkgs.id=sonames.id LEFT JOIN repos ON repos.id=pkgs.repo WHERE sonames.soname=? ORDER BY repos.id, pkgs.name", name)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		p := SonameProvider{}
+		if err := rows.Scan(&p.NAME, &p.VERSION, &p.REPO, &p.SOVERSION, &p.ABI); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		provided := p.SOVERSION
+		if p.ABI != "" {
+			provided += "-" + p.ABI
+		}
+		if sonameMatch(provided, comp, ver) {
+			ret.PROVIDERS = append(ret.PROVIDERS, p)
+		}
+	}
+	rows.Close()
+
+	for _, table := range []string{"depends", "makedepends", "checkdepends", "optdepends"} {
+		depend, field := table+".depend||"+table+".comp||"+table+".ver", table+".depend"
+		if table == "optdepends" {
+			depend, field = "optdepends.optdepend", "optdepends.optdepend"
+		}
+		rows, err := db.Query("SELECT p.name, p.version, ifnull(r.repo, ''), "+depend+", ifnull(s.name, '') FROM "+table+
+			" INNER JOIN pkgs p ON p.id="+table+".id LEFT JOIN repos r ON r.id=p.repo LEFT JOIN pkgs s ON s.id="+table+".pkg"+
+			" WHERE "+field+"=? ORDER BY r.id, p.name", name)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			c := SonameConsumer{TYPE: table}
+			if err := rows.Scan(&c.NAME, &c.VERSION, &c.REPO, &c.DEPEND, &c.PROVIDER); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			ret.CONSUMERS = append(ret.CONSUMERS, c)
+		}
+		rows.Close()
+	}
+	return &ret, nil
+}
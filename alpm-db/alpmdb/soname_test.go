@@ -0,0 +1,75 @@
+package alpmdb
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSoname(t *testing.T) {
+	tests := []struct {
+		provide string
+		want    Soname
+		ok      bool
+	}{
+		{"libssl.so=3-64", Soname{NAME: "libssl.so", VERSION: "3", ABI: "64"}, true},
+		{"libc.so=6-32", Soname{NAME: "libc.so", VERSION: "6", ABI: "32"}, true},
+		{"libfoo.so=1.2.3-64", Soname{NAME: "libfoo.so", VERSION: "1.2.3", ABI: "64"}, true},
+		// version with "-": ABI is after the last one
+		{"libbar.so=1-2-64", Soname{NAME: "libbar.so", VERSION: "1-2", ABI: "64"}, true},
+		{"libbaz.so=2", Soname{NAME: "libbaz.so", VERSION: "2"}, true},
+		{"libqux.so", Soname{NAME: "libqux.so"}, true},
+		{"libold.so.1", Soname{NAME: "libold.so.1"}, true},
+		{"sh", Soname{}, false},
+		{"java-runtime=17", Soname{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseSoname(tt.provide)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseSoname(%q) = %+v, %v, want %+v, %v", tt.provide, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSonameMatch(t *testing.T) {
+	tests := []struct {
+		provided string
+		comp     string
+		ver      string
+		want     bool
+	}{
+		{"3-64", "=", "3-64", true},
+		// other ABI
+		{"3-64", "=", "3-32", false},
+		{"3-64", "=", "2-64", false},
+		{"3-64", ">=", "2-64", true},
+		// depend without ABI
+		{"3-64", "=", "3", true},
+		{"3-64", "", "", true},
+	}
+	for _, tt := range tests {
+		if got := sonameMatch(tt.provided, tt.comp, tt.ver); got != tt.want {
+			t.Errorf("sonameMatch(%q, %q, %q) = %v, want %v", tt.provided, tt.comp, tt.ver, got, tt.want)
+		}
+	}
+}
+
+/*
+ * packages with many soname provides: requests of sonames and provides must stay under 999 variables
+ */
+func TestGenSqliteSonamesBatches(t *testing.T) {
+	pkgs := testPackages(
+		// 124 sonames: 496 variables, not flushed
+		Package{NAME: "a", VERSION: "1.0-1", REPO: "core", PROVIDES: manyValues("liba%d.so=1-64", 124)},
+		Package{NAME: "b", VERSION: "1.0-1", REPO: "core", PROVIDES: append(manyValues("libb%d.so=2-64", 260), "sh")},
+	)
+	dbFile := filepath.Join(t.TempDir(), "sonames.db")
+	if err := GenSqlite(dbFile, pkgs, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if nb := countRows(t, dbFile, "sonames"); nb != 384 {
+		t.Errorf("sonames rows = %d, want 384", nb)
+	}
+	if nb := countRows(t, dbFile, "provides"); nb != 385 {
+		t.Errorf("provides rows = %d, want 385", nb)
+	}
+}
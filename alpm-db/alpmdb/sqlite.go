@@ -0,0 +1,496 @@
+package alpmdb
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// sqlite3 driver with our functions: vercmp(), regexp, and collation vercmp (ORDER BY version COLLATE vercmp)
+// sql.Open(alpmdb.SqlDriver, "pacman.db")
+const SqlDriver = "sqlite3_alpm"
+
+func init() {
+	sql.Register(SqlDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc("vercmp", Vercmp, true); err != nil {
+				return err
+			}
+			if err := conn.RegisterCollation("vercmp", Vercmp); err != nil {
+				return err
+			}
+			if err := conn.RegisterFunc("ftsrank", ftsRank, true); err != nil {
+				return err
+			}
+			return conn.RegisterFunc("regexp", sqlRegexp, true)
+		},
+	})
+}
+
+/*
+ * open an existing database, writes are refused
+ */
+func OpenReadOnly(dbFile string) (*sql.DB, error) {
+	if _, err := os.Stat(dbFile); err != nil {
+		return nil, err
+	}
+	return sql.Open(SqlDriver, "file:"+dbFile+"?mode=ro&_query_only=1")
+}
+
+var sqlRegexps = struct {
+	sync.Mutex
+	items map[string]*regexp.Regexp
+}{items: make(map[string]*regexp.Regexp)}
+
+/*
+ * "X REGEXP Y" call regexp(Y, X)
+ */
+func sqlRegexp(pattern string, value string) (bool, error) {
+	sqlRegexps.Lock()
+	defer sqlRegexps.Unlock()
+	re, found := sqlRegexps.items[pattern]
+	if !found {
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			return false, err
+		}
+		sqlRegexps.items[pattern] = re
+	}
+	return re.MatchString(value), nil
+}
+
+func moveFile(sourcePath, destPath string) error {
+	// canèt use "rename" in /tmp
+	inputFile, err := os.Open(sourcePath)
+	if err != nil {
+		return fmt.Errorf("Couldn't open source file: %s", err)
+	}
+	outputFile, err := os.Create(destPath)
+	if err != nil {
+		inputFile.Close()
+		return fmt.Errorf("Couldn't open dest file: %s", err)
+	}
+	defer outputFile.Close()
+	_, err = io.Copy(outputFile, inputFile)
+	inputFile.Close()
+	if err != nil {
+		return fmt.Errorf("Writing to output file failed: %s", err)
+	}
+	// The copy was successful, so now delete the original file
+	err = os.Remove(sourcePath)
+	if err != nil {
+		return fmt.Errorf("Failed removing original file: %s", err)
+	}
+	return nil
+}
+
+func getSepDepend(dep string) string {
+	seps := []string{">=", "<=", "=", "<", ">"}
+	for _, sep := range seps {
+		if strings.Contains(dep, sep) {
+			return sep
+		}
+	}
+	return ""
+}
+
+/*
+ * "glibc>=2.38" -> "glibc", ">=", "2.38"
+ */
+func splitDepend(dep string) (name string, comp string, ver string) {
+	dep = strings.TrimSpace(dep)
+	comp = getSepDepend(dep)
+	if comp == "" {
+		return dep, "", ""
+	}
+	tmp := strings.SplitN(dep, comp, 2)
+	return tmp[0], comp, tmp[1]
+}
+
+/*
+ * insert rows by requests of at most 500 variables (sqlite limit: 999)
+ * insert: "INSERT INTO table (fields) VALUES ", vals: nbFields values by row
+ */
+func insertBatches(db sqlExecer, insert string, nbFields int, vals []interface{}) error {
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", nbFields), ", ") + ")"
+	size := (500 / nbFields) * nbFields
+	for start := 0; start < len(vals); start += size {
+		end := min(start+size, len(vals))
+		request := insert + strings.TrimSuffix(strings.Repeat(row+",", (end-start)/nbFields), ",")
+		if _, err := db.Exec(request, vals[start:end]...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func GenSqlite(dbFile string, pkgs Packages, installed Packages, infos map[string]*RepoInfo) error {
+	tmpFile := os.TempDir() + "/" + filepath.Base(dbFile)
+	// dbFile is replaced at end, history is read before
+	os.Remove(tmpFile)
+	//defer os.Rename(tmpFile, dbFile)
+	db, err := sql.Open(SqlDriver, tmpFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tstart := time.Now() // start timer
+	/* pkgs: UNIQUE(name)
+	 * ignore duplicate as pacman (by order of repos)
+	 */
+	tables := []string{
+		"CREATE TABLE IF NOT EXISTS pkgs (id INTEGER PRIMARY KEY, name TEXT UNIQUE NOT NULL, base TEXT DEFAULT NULL, version TEXT NOT NULL, repo INTEGER, desc TEXT, url TEXT, builddate TIME, csize INTEGER, isize INTEGER, packager INTEGER, hash TEXT)",
+		"CREATE TABLE IF NOT EXISTS depends (id INTEGER, depend TEXT, comp TEXT, ver TEXT, pkg INTEGER DEFAULT -1)",
+		"CREATE TABLE IF NOT EXISTS optdepends (id INTEGER, optdepend TEXT, pkg INTEGER DEFAULT -1)",
+		"CREATE TABLE IF NOT EXISTS provides (id INTEGER, provide TEXT, comp TEXT, ver TEXT, pkg INTEGER DEFAULT -1)",
+		"CREATE TABLE IF NOT EXISTS conflicts (id INTEGER, conflict TEXT, comp TEXT, ver TEXT, pkg INTEGER DEFAULT -1)",
+		"CREATE TABLE IF NOT EXISTS makedepends (id INTEGER, depend TEXT, comp TEXT, ver TEXT, pkg INTEGER DEFAULT -1)",
+		/* candidates: all packages can satisfy a depend (name or provides)
+		 * type: depends, makedepends, optdepends
+		 * preferred: package selected by pacman (repos order), same as depends.pkg
+		 */
+		"CREATE TABLE IF NOT EXISTS candidates (id INTEGER, type TEXT, depend TEXT, pkg INTEGER, preferred INTEGER DEFAULT 0)",
+		"CREATE TABLE IF NOT EXISTS licences (id INTEGER, licence TEXT)",
+		"CREATE TABLE IF NOT EXISTS packagers (id INTEGER PRIMARY KEY, packager TEXT UNIQUE)",
+		"CREATE TABLE IF NOT EXISTS repos (id INTEGER PRIMARY KEY, repo TEXT UNIQUE, url TEXT, branch TEXT, arch TEXT, siglevel TEXT, sigstatus TEXT, sigkey TEXT)",
+		changesTable,
+	}
+	for _, table := range tables {
+		if _, err := db.Exec(table); err != nil {
+			return err
+		}
+	}
+
+	// before REPO and PACKAGER are replaced by ids
+	hashes := make([]string, len(pkgs))
+	for i := range pkgs {
+		hashes[i] = pkgs[i].checksum()
+	}
+	logln("history table ...")
+	if err := copyHistory(db, dbFile, pkgs, infos); err != nil {
+		return fmt.Errorf("history: %s", err)
+	}
+
+	logln("packagers table ...")
+	j := 1
+	for i, pkg := range pkgs {
+		if len(pkg.PACKAGER) < 1 {
+			continue
+		}
+		ret, err := db.Exec("INSERT or IGNORE INTO packagers (id,packager) VALUES (?, ?)", j, pkg.PACKAGER)
+		if err != nil {
+			return fmt.Errorf("packagers insert: %s", err)
+		}
+		if nb, _ := ret.RowsAffected(); nb > 0 {
+			pkgs[i].PACKAGER = strconv.FormatInt(int64(j), 10)
+			j = j + 1
+			continue
+		}
+		var id int64
+		if err := db.QueryRow("SELECT id FROM packagers WHERE packager=? LIMIT 1", pkg.PACKAGER).Scan(&id); err != nil {
+			return err
+		}
+		pkgs[i].PACKAGER = strconv.FormatInt(id, 10)
+	}
+
+	logln("repos table ...")
+	j = 1
+	for i, pkg := range pkgs {
+		if len(pkg.REPO) < 1 {
+			continue
+		}
+		ret, err := db.Exec("INSERT or IGNORE INTO repos (id,repo) VALUES (?, ?)", j, pkg.REPO)
+		if err != nil {
+			return fmt.Errorf("repos insert: %s", err)
+		}
+		if nb, _ := ret.RowsAffected(); nb > 0 {
+			pkgs[i].REPO = strconv.FormatInt(int64(j), 10)
+			j = j + 1
+			continue
+		}
+		var id int64
+		if err := db.QueryRow("SELECT id FROM repos WHERE repo=? LIMIT 1", pkg.REPO).Scan(&id); err != nil {
+			return err
+		}
+		pkgs[i].REPO = strconv.FormatInt(id, 10)
+	}
+
+	// sync state, also for repos without packages (rejected)
+	for _, info := range sortedRepoInfos(infos) {
+		if _, err := db.Exec("INSERT or IGNORE INTO repos (repo) VALUES (?)", info.NAME); err != nil {
+			return err
+		}
+		if _, err := db.Exec("UPDATE repos SET url=?, branch=?, arch=?, siglevel=?, sigstatus=?, sigkey=? WHERE repo=?", info.URL, info.BRANCH, info.ARCH, info.SIGLEVEL, info.SIGSTATUS, info.SIGKEY, info.NAME); err != nil {
+			return err
+		}
+	}
+
+	logln("main table ...")
+	sqlStr := "INSERT or IGNORE INTO pkgs (id, name, base, version, repo, url, desc, builddate, csize, isize, packager, hash) VALUES "
+	vals := []interface{}{}
+	for i, pkg := range pkgs {
+		sqlStr += "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?),"
+		t := time.Unix(pkg.BUILDDATE, 0)
+		vals = append(vals, pkg.id, pkg.NAME, pkg.getBase(), pkg.VERSION, pkg.REPO, pkg.URL, pkg.DESC, t.Format("2006-01-02 15:04:05"), pkg.CSIZE, pkg.ISIZE, pkg.PACKAGER, hashes[i])
+		if i%50 == 0 {
+			sqlStr = strings.TrimSuffix(sqlStr, ",")
+			stmt, err := db.Prepare(sqlStr)
+			if err != nil {
+				return fmt.Errorf("Prepare: %s", err)
+			}
+			//fmt.Print(i, "... ")
+			_, err = stmt.Exec(vals...)
+			if err != nil {
+				//fmt.Println("Error insert ", i, sqlStr)
+				//fmt.Println("Error insert ", i, vals)
+				return err
+			}
+			sqlStr = "INSERT or IGNORE INTO pkgs (id, name, base, version, repo, url, desc, builddate, csize, isize, packager, hash) VALUES "
+			vals = []interface{}{}
+		}
+	}
+	if len(vals) > 0 {
+		sqlStr = strings.TrimSuffix(sqlStr, ",")
+		if _, err := db.Exec(sqlStr, vals...); err != nil {
+			return fmt.Errorf("pkgs insert: %s", err)
+		}
+	}
+
+	resolver := NewResolver(pkgs)
+
+	logln("depends table ...")
+	for _, pkg := range pkgs {
+		if len(pkg.DEPENDS) < 1 {
+			continue
+		}
+
+		sqlStr := "INSERT INTO depends (id, depend, comp, ver, pkg) VALUES "
+		vals := []interface{}{}
+		for _, dep := range pkg.DEPENDS {
+			name, comp, ver := splitDepend(dep)
+			vals = append(vals, pkg.id, name, comp, ver, resolver.Preferred(dep))
+		}
+		if err := insertBatches(db, sqlStr, 5, vals); err != nil {
+			return fmt.Errorf("depends insert: %s", err)
+		}
+	}
+
+	logln("optional depends table ...")
+	for _, pkg := range pkgs {
+		if len(pkg.OPTDEPENDS) < 1 {
+			continue
+		}
+
+		sqlStr := "INSERT INTO optdepends (id, optdepend, pkg) VALUES "
+		vals := []interface{}{}
+		for _, dep := range pkg.OPTDEPENDS {
+			dep = strings.SplitN(dep, ":", 2)[0]
+			name, _, _ := splitDepend(dep)
+			vals = append(vals, pkg.id, name, resolver.Preferred(dep))
+		}
+		if err := insertBatches(db, sqlStr, 3, vals); err != nil {
+			return fmt.Errorf("optional depends insert: %s", err)
+		}
+	}
+
+	logln("conflits table ...")
+	for _, pkg := range pkgs {
+		if len(pkg.CONFLICTS) < 1 {
+			continue
+		}
+
+		sqlStr := "INSERT INTO conflicts (id, conflict, comp, ver, pkg) VALUES "
+		vals := []interface{}{}
+		for _, dep := range pkg.CONFLICTS {
+			dep = strings.TrimSpace(dep)
+			ver := ""
+			comp := getSepDepend(dep)
+			if comp != "" {
+				tmp := strings.SplitN(dep, comp, 2)
+				ver = tmp[1]
+				dep = tmp[0]
+			}
+			vals = append(vals, pkg.id, dep, comp, ver, pkgs.FindByName(dep))
+		}
+		if err := insertBatches(db, sqlStr, 5, vals); err != nil {
+			return fmt.Errorf("conflicts insert: %s", err)
+		}
+	}
+
+	logln("provides table ...")
+	for _, pkg := range pkgs {
+		if len(pkg.PROVIDES) < 1 {
+			continue
+		}
+
+		sqlStr := "INSERT INTO provides (id, provide, comp, ver, pkg) VALUES "
+		vals := []interface{}{}
+		for _, dep := range pkg.PROVIDES {
+			dep = strings.TrimSpace(dep)
+			ver := ""
+			comp := getSepDepend(dep)
+			if comp != "" {
+				tmp := strings.SplitN(dep, comp, 2)
+				ver = tmp[1]
+				dep = tmp[0]
+			}
+			vals = append(vals, pkg.id, dep, comp, ver, pkgs.FindByName(dep))
+		}
+		if err := insertBatches(db, sqlStr, 5, vals); err != nil {
+			return fmt.Errorf("provides insert: %s", err)
+		}
+	}
+
+	logln("licences table ...")
+	for _, pkg := range pkgs {
+		if len(pkg.LICENSE) < 1 {
+			continue
+		}
+
+		sqlStr := "INSERT INTO licences (id, licence) VALUES "
+		vals := []interface{}{}
+		for _, dep := range pkg.LICENSE {
+			vals = append(vals, pkg.id, strings.TrimSpace(dep))
+		}
+		if err := insertBatches(db, sqlStr, 2, vals); err != nil {
+			return fmt.Errorf("licences insert: %s", err)
+		}
+	}
+
+	for _, pkg := range pkgs {
+		if len(pkg.MAKEDEPENDS) < 1 {
+			continue
+		}
+		sqlStr := "INSERT INTO makedepends (id, depend, comp, ver, pkg) VALUES "
+		vals := []interface{}{}
+		for _, dep := range pkg.MAKEDEPENDS {
+			name, comp, ver := splitDepend(dep)
+			vals = append(vals, pkg.id, name, comp, ver, resolver.Preferred(dep))
+		}
+		if err := insertBatches(db, sqlStr, 5, vals); err != nil {
+			return fmt.Errorf("makedepends insert: %s", err)
+		}
+	}
+
+	logln("replaces, groups, checkdepends, checksums, xdata tables ...")
+	if err := genSqliteDesc(db, pkgs, resolver); err != nil {
+		return err
+	}
+
+	logln("candidates table ...")
+	sqlStr = "INSERT INTO candidates (id, type, depend, pkg, preferred) VALUES "
+	vals = []interface{}{}
+	flushCandidates := func() error {
+		if len(vals) < 1 {
+			return nil
+		}
+		sqlStr = strings.TrimSuffix(sqlStr, ",")
+		_, err = db.Exec(sqlStr, vals...)
+		if err != nil {
+			return fmt.Errorf("candidates insert: %s", err)
+		}
+		sqlStr = "INSERT INTO candidates (id, type, depend, pkg, preferred) VALUES "
+		vals = []interface{}{}
+		return nil
+	}
+	for _, pkg := range pkgs {
+		for _, typ := range []string{"depends", "makedepends", "optdepends"} {
+			items := pkg.DEPENDS
+			if typ == "makedepends" {
+				items = pkg.MAKEDEPENDS
+			} else if typ == "optdepends" {
+				items = pkg.OPTDEPENDS
+			}
+			for _, dep := range items {
+				name, _, _ := splitDepend(dep)
+				for i, id := range resolver.Resolve(dep) {
+					sqlStr += "(?, ?, ?, ?, ?),"
+					vals = append(vals, pkg.id, typ, name, id, i == 0)
+				}
+				// sqlite limit: 999 variables by request
+				if len(vals) > 500 {
+					if err := flushCandidates(); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	if err := flushCandidates(); err != nil {
+		return err
+	}
+
+	logln("files table ...")
+	if err := genSqliteFiles(db, pkgs); err != nil {
+		return err
+	}
+
+	if len(installed) > 0 {
+		logln("installed table ...")
+		if err := genSqliteInstalled(db, installed, pkgs); err != nil {
+			return err
+		}
+	}
+
+	logln("full text index ...")
+	if err := genSqliteFts(db); err != nil {
+		return fmt.Errorf("full text index: %s", err)
+	}
+
+	logln("create index...")
+	for _, index := range []string{"CREATE INDEX index_repo ON pkgs (repo ASC)", "CREATE INDEX index_name ON pkgs (name ASC)"} {
+		if _, err := db.Exec(index); err != nil {
+			return err
+		}
+	}
+
+	/*
+		// replaced by pkgs.FindByName(dep)
+		fmt.Println("JOIN depends to pkgs...")
+		stmt, _ = db.Prepare("update depends set pkg=(select id from pkgs where depends.depend LIKE pkgs.name LIMIT 1)")
+		_, err = stmt.Exec()
+		if err != nil {
+			return fmt.Errorf("depends link depend name to package id: %s", err)
+		}
+		fmt.Println("JOIN makedepends to pkgs...")
+		stmt, _ = db.Prepare("update makedepends set pkg=(select id from pkgs where makedepends.depend LIKE pkgs.name LIMIT 1)")
+		_, err = stmt.Exec()
+		if err != nil {
+			return fmt.Errorf("makedepends link depend name to package id: %s", err)
+		}
+	*/
+
+	telapsed := time.Since(tstart)
+	logln("sql duration:", telapsed)
+
+	var nb int64
+	// SELECT count(DISTINCT id)
+	err = db.QueryRow("SELECT count(id) AS nb FROM pkgs").Scan(&nb)
+	if err != nil {
+		return err
+	}
+	logln(nb, "pkgs in Database sql")
+	/*
+		select name,builddate from pkgs where strftime('%Y',builddate)='2013'
+
+		SELECT pkgs.id, name, depend  FROM pkgs LEFT JOIN depends ON pkgs.id=depends.id WHERE depend='gtk2' order by name
+		SELECT pkgs.id, name, depend  FROM pkgs LEFT JOIN makedepends ON pkgs.id=makedepends.id WHERE depend='gtk2' order by name
+
+		SELECT *  FROM packagers WHERE packager LIKE '%manjaro%' order by packager
+		SELECT name, packagers.packager FROM pkgs LEFT JOIN packagers ON pkgs.packager=packagers.id WHERE packagers.packager LIKE '%manjaro%' order by packagers.packager
+
+		SELECT count(name) as "count", packagers.packager, packagers.id FROM pkgs LEFT JOIN packagers ON pkgs.packager=packagers.id GROUP BY packagers.id HAVING packagers.packager LIKE '%manjaro%' order by "count" DESC
+	*/
+
+	return moveFile(tmpFile, dbFile)
+}
@@ -0,0 +1,545 @@
+package alpmdb
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+/*
+ * log of UpdateSqlite, one row by package changed
+ * date is the same for all rows of an update
+ */
+const changesTable = "CREATE TABLE IF NOT EXISTS changes (date TIME, name TEXT, status TEXT, oldversion TEXT, newversion TEXT, oldrepo TEXT, newrepo TEXT)"
+
+// tables with rows by package (field id)
+var relationTables = []string{"depends", "optdepends", "makedepends", "provides", "conflicts", "licences", "files", "replaces", "groups", "checkdepends", "checksums", "xdata", "sonames"}
+
+/*
+ * package saved in pacman.db
+ */
+type dbPackage struct {
+	id      int32
+	version string
+	repo    string
+	hash    string
+}
+
+/*
+ * update an existing pacman.db, only changed packages are inserted, updated or deleted
+ * all in one transaction, changes are saved in table changes
+ * pacman.db not exists, without pkgs.hash, with full text index over pkgs (old versions) or with a fts5 index not usable by this build: full generation by GenSqlite
+ */
+func UpdateSqlite(dbFile string, pkgs Packages, installed Packages, infos map[string]*RepoInfo) ([]PackageDiff, error) {
+	if !hasHash(dbFile) {
+		logln("no pacman.db to update, full generation")
+		return nil, GenSqlite(dbFile, pkgs, installed, infos)
+	}
+	db, err := sql.Open(SqlDriver, dbFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tstart := time.Now() // start timer
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := updateSqlite(tx, pkgs, installed, infos)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	logln("sql duration:", time.Since(tstart))
+	logln(len(changes), "packages changed")
+	return changes, nil
+}
+
+func hasHash(dbFile string) bool {
+	if _, err := os.Stat(dbFile); err != nil {
+		return false
+	}
+	db, err := sql.Open(SqlDriver, dbFile)
+	if err != nil {
+		return false
+	}
+	defer db.Close()
+	rows, err := db.Query("SELECT hash FROM pkgs LIMIT 1")
+	if err != nil {
+		return false
+	}
+	rows.Close()
+	if hasTable(db, "main", "pkgs_fts") {
+		// index of previous version
+		return false
+	}
+	// fts5 index not usable by this build
+	return !hasTable(db, "main", ftsTable) || ftsVersion(db) != ""
+}
+
+func updateSqlite(tx *sql.Tx, pkgs Packages, installed Packages, infos map[string]*RepoInfo) ([]PackageDiff, error) {
+	newTables := missingDescTables(tx)
+	for _, table := range append([]string{changesTable, "CREATE TABLE IF NOT EXISTS files (id INTEGER, file TEXT)"}, descTables...) {
+		if _, err := tx.Exec(table); err != nil {
+			return nil, err
+		}
+	}
+
+	// rows of duplicate packages (same name in next repos) by GenSqlite
+	for _, table := range append(relationTables, "candidates") {
+		if _, err := tx.Exec("DELETE FROM " + table + " WHERE id NOT IN (SELECT id FROM pkgs)"); err != nil {
+			return nil, err
+		}
+	}
+
+	// repos.branch: pacman.db of previous version
+	tx.Exec("ALTER TABLE repos ADD COLUMN branch TEXT")
+	tx.Exec("ALTER TABLE repos ADD COLUMN arch TEXT")
+	// packages of other architecture would be saved as changes
+	if _, arch := syncBranch(infos); arch != "" {
+		saved := dbArch(tx, "main")
+		if saved == "" {
+			saved = DefaultArch
+		}
+		if saved != arch {
+			return nil, fmt.Errorf("pacman.db is for %s, not %s: can not be updated, sync without --update", saved, arch)
+		}
+	}
+	// before packages are changed: last sync of the branch of pacman.db
+	if hasTable(tx, "main", "pkg_history") {
+		if err := migrateHistory(tx); err != nil {
+			return nil, err
+		}
+		if !hasTable(tx, "main", "branch_pkgs") {
+			if err := initBranchPackages(tx, "main"); err != nil {
+				return nil, err
+			}
+		} else if err := migrateBranchPackages(tx); err != nil {
+			return nil, err
+		}
+	}
+	for _, table := range []string{historyTable, branchPkgsTable} {
+		if _, err := tx.Exec(table); err != nil {
+			return nil, err
+		}
+	}
+
+	logln("compare packages ...")
+	olds := make(map[string]dbPackage)
+	var maxId int32
+	rows, err := tx.Query("SELECT pkgs.id, pkgs.name, pkgs.version, ifnull(repos.repo, ''), ifnull(pkgs.hash, '') FROM pkgs LEFT JOIN repos ON repos.id=pkgs.repo")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name string
+		old := dbPackage{}
+		if err := rows.Scan(&old.id, &name, &old.version, &old.repo, &old.hash); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		olds[name] = old
+		if old.id > maxId {
+			maxId = old.id
+		}
+	}
+	rows.Close()
+
+	// as pacman, ignore same package in next repos
+	// saved packages keep their id
+	news := make(Packages, 0, len(pkgs))
+	seen := make(map[string]bool, len(pkgs))
+	for _, pkg := range pkgs {
+		if seen[pkg.NAME] {
+			continue
+		}
+		seen[pkg.NAME] = true
+		if old, found := olds[pkg.NAME]; found {
+			pkg.id = old.id
+		} else {
+			maxId++
+			pkg.id = maxId
+		}
+		news = append(news, pkg)
+	}
+	resolver := NewResolver(news)
+
+	ids := newTableIds(tx)
+	changes := []PackageDiff{}
+	for i := range news {
+		pkg := &news[i]
+		old, found := olds[pkg.NAME]
+		hash := pkg.checksum()
+		switch {
+		case !found:
+			changes = append(changes, PackageDiff{NAME: pkg.NAME, STATUS: DIFF_ADDED, NEWVERSION: pkg.VERSION, NEWREPO: pkg.REPO})
+		case old.hash != hash:
+			status := DIFF_UPDATED
+			switch Vercmp(pkg.VERSION, old.version) {
+			case 1:
+				status = DIFF_UPGRADED
+			case -1:
+				status = DIFF_DOWNGRADED
+			}
+			changes = append(changes, PackageDiff{NAME: pkg.NAME, STATUS: status, OLDVERSION: old.version, NEWVERSION: pkg.VERSION, OLDREPO: old.repo, NEWREPO: pkg.REPO})
+			if err := deleteRelations(tx, pkg.id); err != nil {
+				return nil, err
+			}
+		default:
+			continue
+		}
+		if err := insertPackage(tx, pkg, hash, ids, resolver); err != nil {
+			return nil, err
+		}
+	}
+	for name, old := range olds {
+		if seen[name] {
+			continue
+		}
+		changes = append(changes, PackageDiff{NAME: name, STATUS: DIFF_REMOVED, OLDVERSION: old.version, OLDREPO: old.repo})
+		if err := deleteRelations(tx, old.id); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("DELETE FROM pkgs WHERE id=?", old.id); err != nil {
+			return nil, err
+		}
+	}
+	sortDiffs(changes)
+
+	if len(newTables) > 0 {
+		// pacman.db of previous version
+		logln("new tables ...")
+		if err := fillDescTables(tx, newTables, news, resolver); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(changes) > 0 {
+		// added or removed packages can change the selected package of others
+		logln("resolve depends ...")
+		if err := refreshResolved(tx, news, resolver); err != nil {
+			return nil, err
+		}
+		logln("candidates table ...")
+		if err := refreshCandidates(tx, news, resolver); err != nil {
+			return nil, err
+		}
+		logln("changes table ...")
+		date := time.Now().Format("2006-01-02 15:04:05")
+		for _, c := range changes {
+			if _, err := tx.Exec("INSERT INTO changes (date, name, status, oldversion, newversion, oldrepo, newrepo) VALUES (?, ?, ?, ?, ?, ?, ?)",
+				date, c.NAME, c.STATUS, c.OLDVERSION, c.NEWVERSION, c.OLDREPO, c.NEWREPO); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// compared with the last sync of the same branch, not with pacman.db
+	logln("history table ...")
+	if err := saveHistory(tx, news, infos); err != nil {
+		return nil, err
+	}
+	// rowids of branch_pkgs are new
+	logln("full text index ...")
+	if err := genSqliteFts(tx); err != nil {
+		return nil, err
+	}
+
+	for _, info := range sortedRepoInfos(infos) {
+		if _, err := ids.get("repos", "repo", info.NAME); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("UPDATE repos SET url=?, branch=?, arch=?, siglevel=?, sigstatus=?, sigkey=? WHERE repo=?", info.URL, info.BRANCH, info.ARCH, info.SIGLEVEL, info.SIGSTATUS, info.SIGKEY, info.NAME); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(installed) > 0 {
+		// installed table is small: replaced
+		logln("installed table ...")
+		for _, request := range []string{installedTable, "DELETE FROM installed"} {
+			if _, err := tx.Exec(request); err != nil {
+				return nil, err
+			}
+		}
+		if err := insertInstalled(tx, installed, news); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+/*
+ * ids of tables repos and packagers, rows are created if not exist
+ */
+type tableIds struct {
+	tx    *sql.Tx
+	cache map[string]sql.NullInt64
+}
+
+func newTableIds(tx *sql.Tx) *tableIds {
+	return &tableIds{tx: tx, cache: make(map[string]sql.NullInt64)}
+}
+
+func (t *tableIds) get(table string, field string, value string) (sql.NullInt64, error) {
+	ret := sql.NullInt64{}
+	if value == "" {
+		return ret, nil
+	}
+	if id, found := t.cache[table+"/"+value]; found {
+		return id, nil
+	}
+	if _, err := t.tx.Exec("INSERT or IGNORE INTO "+table+" ("+field+") VALUES (?)", value); err != nil {
+		return ret, err
+	}
+	if err := t.tx.QueryRow("SELECT id FROM "+table+" WHERE "+field+"=?", value).Scan(&ret); err != nil {
+		return ret, err
+	}
+	t.cache[table+"/"+value] = ret
+	return ret, nil
+}
+
+func deleteRelations(tx *sql.Tx, id int32) error {
+	for _, table := range relationTables {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE id=?", id); err != nil {
+			return fmt.Errorf("%s delete: %s", table, err)
+		}
+	}
+	return nil
+}
+
+/*
+ * insert or replace a package and its relations (not candidates)
+ */
+func insertPackage(tx *sql.Tx, pkg *Package, hash string, ids *tableIds, resolver *Resolver) error {
+	repo, err := ids.get("repos", "repo", pkg.REPO)
+	if err != nil {
+		return err
+	}
+	packager, err := ids.get("packagers", "packager", pkg.PACKAGER)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT or REPLACE INTO pkgs (id, name, base, version, repo, url, desc, builddate, csize, isize, packager, hash) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		pkg.id, pkg.NAME, pkg.getBase(), pkg.VERSION, repo, pkg.URL, pkg.DESC, time.Unix(pkg.BUILDDATE, 0).Format("2006-01-02 15:04:05"), pkg.CSIZE, pkg.ISIZE, packager, hash)
+	if err != nil {
+		return fmt.Errorf("pkgs insert %s: %s", pkg.NAME, err)
+	}
+
+	requests := []struct {
+		request string
+		values  func() [][]interface{}
+	}{
+		{"INSERT INTO depends (id, depend, comp, ver, pkg) VALUES (?, ?, ?, ?, ?)", func() (ret [][]interface{}) {
+			for _, dep := range pkg.DEPENDS {
+				name, comp, ver := splitDepend(dep)
+				ret = append(ret, []interface{}{pkg.id, name, comp, ver, resolver.Preferred(dep)})
+			}
+			return ret
+		}},
+		{"INSERT INTO optdepends (id, optdepend, pkg) VALUES (?, ?, ?)", func() (ret [][]interface{}) {
+			for _, dep := range pkg.OPTDEPENDS {
+				dep = strings.SplitN(dep, ":", 2)[0]
+				name, _, _ := splitDepend(dep)
+				ret = append(ret, []interface{}{pkg.id, name, resolver.Preferred(dep)})
+			}
+			return ret
+		}},
+		{"INSERT INTO makedepends (id, depend, comp, ver, pkg) VALUES (?, ?, ?, ?, ?)", func() (ret [][]interface{}) {
+			for _, dep := range pkg.MAKEDEPENDS {
+				name, comp, ver := splitDepend(dep)
+				ret = append(ret, []interface{}{pkg.id, name, comp, ver, resolver.Preferred(dep)})
+			}
+			return ret
+		}},
+		{"INSERT INTO provides (id, provide, comp, ver, pkg) VALUES (?, ?, ?, ?, ?)", func() (ret [][]interface{}) {
+			for _, dep := range pkg.PROVIDES {
+				name, comp, ver := splitDepend(dep)
+				ret = append(ret, []interface{}{pkg.id, name, comp, ver, resolver.byName(name)})
+			}
+			return ret
+		}},
+		{"INSERT INTO conflicts (id, conflict, comp, ver, pkg) VALUES (?, ?, ?, ?, ?)", func() (ret [][]interface{}) {
+			for _, dep := range pkg.CONFLICTS {
+				name, comp, ver := splitDepend(dep)
+				ret = append(ret, []interface{}{pkg.id, name, comp, ver, resolver.byName(name)})
+			}
+			return ret
+		}},
+		{"INSERT INTO licences (id, licence) VALUES (?, ?)", func() (ret [][]interface{}) {
+			for _, licence := range pkg.LICENSE {
+				ret = append(ret, []interface{}{pkg.id, strings.TrimSpace(licence)})
+			}
+			return ret
+		}},
+		{"INSERT INTO files (id, file) VALUES (?, ?)", func() (ret [][]interface{}) {
+			for _, file := range pkg.FILES {
+				ret = append(ret, []interface{}{pkg.id, file})
+			}
+			return ret
+		}},
+	}
+	for _, r := range requests {
+		for _, values := range r.values() {
+			if _, err := tx.Exec(r.request, values...); err != nil {
+				return fmt.Errorf("%s: %s", pkg.NAME, err)
+			}
+		}
+	}
+	return insertDesc(tx, pkg, resolver)
+}
+
+/*
+ * field pkg of depends, optdepends, makedepends, checkdepends, provides and conflicts
+ * only rows with an other result are updated
+ */
+func refreshResolved(tx *sql.Tx, pkgs Packages, resolver *Resolver) error {
+	wanted := map[string]map[string]sql.NullInt32{
+		"depends":      {},
+		"optdepends":   {},
+		"makedepends":  {},
+		"checkdepends": {},
+		"provides":     {},
+		"conflicts":    {},
+	}
+	for _, pkg := range pkgs {
+		for _, dep := range pkg.DEPENDS {
+			name, comp, ver := splitDepend(dep)
+			wanted["depends"][fmt.Sprint(pkg.id, "|", name+comp+ver)] = resolver.Preferred(dep)
+		}
+		for _, dep := range pkg.MAKEDEPENDS {
+			name, comp, ver := splitDepend(dep)
+			wanted["makedepends"][fmt.Sprint(pkg.id, "|", name+comp+ver)] = resolver.Preferred(dep)
+		}
+		for _, dep := range pkg.CHECKDEPENDS {
+			name, comp, ver := splitDepend(dep)
+			wanted["checkdepends"][fmt.Sprint(pkg.id, "|", name+comp+ver)] = resolver.Preferred(dep)
+		}
+		for _, dep := range pkg.OPTDEPENDS {
+			dep = strings.SplitN(dep, ":", 2)[0]
+			name, _, _ := splitDepend(dep)
+			wanted["optdepends"][fmt.Sprint(pkg.id, "|", name)] = resolver.Preferred(dep)
+		}
+		for _, dep := range pkg.PROVIDES {
+			name, comp, ver := splitDepend(dep)
+			wanted["provides"][fmt.Sprint(pkg.id, "|", name+comp+ver)] = resolver.byName(name)
+		}
+		for _, dep := range pkg.CONFLICTS {
+			name, comp, ver := splitDepend(dep)
+			wanted["conflicts"][fmt.Sprint(pkg.id, "|", name+comp+ver)] = resolver.byName(name)
+		}
+	}
+
+	fields := map[string]string{
+		"depends":      "depend||comp||ver",
+		"optdepends":   "optdepend",
+		"makedepends":  "depend||comp||ver",
+		"checkdepends": "depend||comp||ver",
+		"provides":     "provide||comp||ver",
+		"conflicts":    "conflict||comp||ver",
+	}
+	for table, field := range fields {
+		rows, err := tx.Query("SELECT rowid, id, " + field + ", pkg FROM " + table)
+		if err != nil {
+			return err
+		}
+		updates := [][]interface{}{}
+		for rows.Next() {
+			var rowid int64
+			var id int32
+			var depend string
+			var pkg sql.NullInt32
+			if err := rows.Scan(&rowid, &id, &depend, &pkg); err != nil {
+				rows.Close()
+				return err
+			}
+			if want := wanted[table][fmt.Sprint(id, "|", depend)]; want != pkg {
+				updates = append(updates, []interface{}{want, rowid})
+			}
+		}
+		rows.Close()
+		for _, values := range updates {
+			if _, err := tx.Exec("UPDATE "+table+" SET pkg=? WHERE rowid=?", values...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/*
+ * candidates table: only missing rows are inserted, obsolete rows deleted
+ */
+func refreshCandidates(tx *sql.Tx, pkgs Packages, resolver *Resolver) error {
+	// key: id|type|depend|pkg -> preferred
+	wanted := make(map[string]bool)
+	for _, pkg := range pkgs {
+		for _, typ := range []string{"depends", "makedepends", "optdepends"} {
+			items := pkg.DEPENDS
+			if typ == "makedepends" {
+				items = pkg.MAKEDEPENDS
+			} else if typ == "optdepends" {
+				items = pkg.OPTDEPENDS
+			}
+			for _, dep := range items {
+				name, _, _ := splitDepend(dep)
+				for i, id := range resolver.Resolve(dep) {
+					wanted[fmt.Sprint(pkg.id, "|", typ, "|", name, "|", id)] = i == 0
+				}
+			}
+		}
+	}
+
+	rows, err := tx.Query("SELECT rowid, id, type, depend, pkg, preferred FROM candidates")
+	if err != nil {
+		return err
+	}
+	deletes := []int64{}
+	updates := [][]interface{}{}
+	for rows.Next() {
+		var rowid int64
+		var id, pkg int32
+		var typ, depend string
+		var preferred bool
+		if err := rows.Scan(&rowid, &id, &typ, &depend, &pkg, &preferred); err != nil {
+			rows.Close()
+			return err
+		}
+		key := fmt.Sprint(id, "|", typ, "|", depend, "|", pkg)
+		want, found := wanted[key]
+		if !found {
+			deletes = append(deletes, rowid)
+			continue
+		}
+		if want != preferred {
+			updates = append(updates, []interface{}{want, rowid})
+		}
+		// next same row is a duplicate
+		delete(wanted, key)
+	}
+	rows.Close()
+
+	for _, rowid := range deletes {
+		if _, err := tx.Exec("DELETE FROM candidates WHERE rowid=?", rowid); err != nil {
+			return err
+		}
+	}
+	for _, values := range updates {
+		if _, err := tx.Exec("UPDATE candidates SET preferred=? WHERE rowid=?", values...); err != nil {
+			return err
+		}
+	}
+	for key, preferred := range wanted {
+		tmp := strings.SplitN(key, "|", 4)
+		if _, err := tx.Exec("INSERT INTO candidates (id, type, depend, pkg, preferred) VALUES (?, ?, ?, ?, ?)", tmp[0], tmp[1], tmp[2], tmp[3], preferred); err != nil {
+			return err
+		}
+	}
+	return nil
+}
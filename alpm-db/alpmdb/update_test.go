@@ -0,0 +1,344 @@
+package alpmdb
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+/*
+ * packages with ids as ExtractTarGz, a new slice by call:
+ * GenSqlite replaces REPO and PACKAGER by ids
+ */
+func testPackages(pkgs ...Package) Packages {
+	ret := make(Packages, len(pkgs))
+	for i, pkg := range pkgs {
+		pkg.id = int32(i) + 1
+		pkg.ARCH = "x86_64"
+		pkg.PACKAGER = "Tester <test@example.org>"
+		pkg.BUILDDATE = 1700000000
+		ret[i] = pkg
+	}
+	return ret
+}
+
+/*
+ * first sync
+ * app: depends on glibc<2.39 (removed by upgrade), sh (bash, then package sh) and oldpkg (removed)
+ */
+func testPackagesBefore() Packages {
+	return testPackages(
+		Package{NAME: "glibc", VERSION: "2.38-1", REPO: "core", PROVIDES: []string{"libc.so=6-64"}},
+		Package{NAME: "bash", VERSION: "5.2-1", REPO: "core", DEPENDS: []string{"glibc>=2.38", "readline"}, PROVIDES: []string{"sh"}},
+		Package{NAME: "readline", VERSION: "8.2-1", REPO: "core", DEPENDS: []string{"glibc"}},
+		Package{NAME: "oldpkg", VERSION: "1.0-1", REPO: "extra", GROUPS: []string{"old"}},
+		Package{NAME: "app", VERSION: "1.0-1", REPO: "extra", DEPENDS: []string{"sh", "glibc<2.39", "libc.so=6-64", "oldpkg"}, OPTDEPENDS: []string{"readline: line editing"}, MAKEDEPENDS: []string{"bash"}, CHECKDEPENDS: []string{"oldpkg"}},
+		// duplicate in next repo, ignored
+		Package{NAME: "bash", VERSION: "5.0-1", REPO: "extra"},
+	)
+}
+
+/*
+ * next sync: glibc and bash upgraded, oldpkg removed, sh added (new provider for app)
+ */
+func testPackagesAfter() Packages {
+	return testPackages(
+		Package{NAME: "glibc", VERSION: "2.39-1", REPO: "core", PROVIDES: []string{"libc.so=6-64"}},
+		Package{NAME: "bash", VERSION: "5.3-1", REPO: "core", DEPENDS: []string{"glibc>=2.39", "readline"}, PROVIDES: []string{"sh"}},
+		Package{NAME: "readline", VERSION: "8.2-1", REPO: "core", DEPENDS: []string{"glibc"}},
+		Package{NAME: "app", VERSION: "1.0-1", REPO: "extra", DEPENDS: []string{"sh", "glibc<2.39", "libc.so=6-64", "oldpkg"}, OPTDEPENDS: []string{"readline: line editing"}, MAKEDEPENDS: []string{"bash"}, CHECKDEPENDS: []string{"oldpkg"}},
+		Package{NAME: "sh", VERSION: "0.5-1", REPO: "extra", DEPENDS: []string{"glibc"}},
+		Package{NAME: "bash", VERSION: "5.0-1", REPO: "extra"},
+	)
+}
+
+/*
+ * content of a table, package ids replaced by names
+ * ids are not the same after an update and a full generation
+ */
+func dumpTable(t *testing.T, db *sql.DB, table string, names map[int64]string) []string {
+	t.Helper()
+	rows, err := db.Query("SELECT * FROM " + table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	columns, _ := rows.Columns()
+	ret := []string{}
+	for rows.Next() {
+		vals := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			t.Fatal(err)
+		}
+		fields := make([]string, len(columns))
+		orphan := false
+		for i, column := range columns {
+			if id, ok := vals[i].(int64); ok && (column == "id" || column == "pkg") {
+				name, found := names[id]
+				if column == "id" && !found {
+					// rows of a duplicate package by GenSqlite
+					orphan = true
+				}
+				fields[i] = column + "=" + name
+				continue
+			}
+			fields[i] = fmt.Sprintf("%s=%v", column, vals[i])
+		}
+		if !orphan {
+			ret = append(ret, strings.Join(fields, " "))
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func dumpDb(t *testing.T, dbFile string) map[string][]string {
+	t.Helper()
+	db, err := OpenReadOnly(dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	names := make(map[int64]string)
+	rows, err := db.Query("SELECT id, name FROM pkgs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			t.Fatal(err)
+		}
+		names[id] = name
+	}
+	rows.Close()
+
+	ret := make(map[string][]string)
+	pkgs := []string{}
+	rows, err = db.Query(`SELECT pkgs.name, pkgs.version, repos.repo, packagers.packager, ifnull(pkgs.base, ''), pkgs.desc, strftime('%s', pkgs.builddate), pkgs.hash
+		FROM pkgs LEFT JOIN repos ON repos.id=pkgs.repo LEFT JOIN packagers ON packagers.id=pkgs.packager`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		fields := make([]string, 8)
+		ptrs := make([]interface{}, len(fields))
+		for i := range fields {
+			ptrs[i] = &fields[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			t.Fatal(err)
+		}
+		pkgs = append(pkgs, strings.Join(fields, " "))
+	}
+	rows.Close()
+	sort.Strings(pkgs)
+	ret["pkgs"] = pkgs
+	for _, table := range append(relationTables, "candidates") {
+		ret[table] = dumpTable(t, db, table, names)
+	}
+	return ret
+}
+
+func TestUpdateSqlite(t *testing.T) {
+	dir := t.TempDir()
+	updated := filepath.Join(dir, "updated.db")
+	full := filepath.Join(dir, "full.db")
+
+	if err := GenSqlite(updated, testPackagesBefore(), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := UpdateSqlite(updated, testPackagesAfter(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, c := range changes {
+		got = append(got, c.NAME+":"+c.STATUS)
+	}
+	sort.Strings(got)
+	want := []string{"bash:" + DIFF_UPGRADED, "glibc:" + DIFF_UPGRADED, "oldpkg:" + DIFF_REMOVED, "sh:" + DIFF_ADDED}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changes = %v, want %v", got, want)
+	}
+
+	if err := GenSqlite(full, testPackagesAfter(), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	a := dumpDb(t, updated)
+	b := dumpDb(t, full)
+	for table, rows := range b {
+		if !reflect.DeepEqual(a[table], rows) {
+			t.Errorf("table %s after update:\n%s\nfull generation:\n%s", table, strings.Join(a[table], "\n"), strings.Join(rows, "\n"))
+		}
+	}
+
+	// unchanged package, resolved again: sh is now the package, glibc<2.39 and oldpkg are missing
+	db, err := OpenReadOnly(updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	resolved := make(map[string]string)
+	rows, err := db.Query("SELECT depends.depend, ifnull(p.name, '') FROM depends JOIN pkgs ON pkgs.id=depends.id LEFT JOIN pkgs p ON p.id=depends.pkg WHERE pkgs.name='app'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var depend, pkg string
+		if err := rows.Scan(&depend, &pkg); err != nil {
+			t.Fatal(err)
+		}
+		resolved[depend] = pkg
+	}
+	wantResolved := map[string]string{"sh": "sh", "glibc": "", "libc.so": "glibc", "oldpkg": ""}
+	if !reflect.DeepEqual(resolved, wantResolved) {
+		t.Errorf("depends of app = %v, want %v", resolved, wantResolved)
+	}
+}
+
+func TestUpdateSqliteNotModified(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "same.db")
+	if err := GenSqlite(dbFile, testPackagesBefore(), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := UpdateSqlite(dbFile, testPackagesBefore(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) > 0 {
+		t.Errorf("changes = %v, want none", changes)
+	}
+}
+
+func TestResolver(t *testing.T) {
+	pkgs := testPackages(
+		Package{NAME: "bash", VERSION: "5.2-1", PROVIDES: []string{"sh"}},
+		Package{NAME: "dash", VERSION: "0.5-1", PROVIDES: []string{"sh"}},
+		Package{NAME: "glibc", VERSION: "2.38-1", PROVIDES: []string{"libc.so=6-64"}},
+		Package{NAME: "java-runtime", VERSION: "1.0-1"},
+		Package{NAME: "jre17", VERSION: "17-1", PROVIDES: []string{"java-runtime=17"}},
+		Package{NAME: "jre21", VERSION: "21-1", PROVIDES: []string{"java-runtime=21"}},
+		// duplicate in next repo, ignored
+		Package{NAME: "bash", VERSION: "4.0-1"},
+	)
+	r := NewResolver(pkgs)
+	tests := []struct {
+		depend string
+		want   []int32
+	}{
+		// by name first, then provides in repos order
+		{"sh", []int32{1, 2}},
+		{"bash", []int32{1}},
+		{"bash>=5", []int32{1}},
+		{"bash<5", []int32{}},
+		// provide without version only for depend without version
+		{"java-runtime", []int32{4, 5, 6}},
+		{"java-runtime>=17", []int32{5, 6}},
+		{"java-runtime=21", []int32{6}},
+		// sonames only by provides
+		{"libc.so=6-64", []int32{3}},
+		{"libc.so=6-32", []int32{}},
+		{"nope", []int32{}},
+	}
+	for _, tt := range tests {
+		if got := r.Resolve(tt.depend); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Resolve(%q) = %v, want %v", tt.depend, got, tt.want)
+		}
+	}
+	if got := r.Preferred("sh"); !got.Valid || got.Int32 != 1 {
+		t.Errorf("Preferred(sh) = %v, want 1", got)
+	}
+	if got := r.Preferred("nope"); got.Valid {
+		t.Errorf("Preferred(nope) = %v, want NULL", got)
+	}
+}
+
+func TestUpdateSqliteOtherArch(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "arch.db")
+	if err := GenSqlite(dbFile, testPackagesBefore(), nil, testInfos("stable")); err != nil {
+		t.Fatal(err)
+	}
+	before := dumpDb(t, dbFile)
+	infos := testInfos("stable")
+	infos["core"].ARCH = "aarch64"
+	if _, err := UpdateSqlite(dbFile, testPackagesAfter(), nil, infos); err == nil {
+		t.Error("update with other arch: no error")
+	}
+	if after := dumpDb(t, dbFile); !reflect.DeepEqual(before, after) {
+		t.Error("update with other arch: pacman.db is modified")
+	}
+}
+
+func dumpRepos(t *testing.T, dbFile string) []string {
+	t.Helper()
+	db, err := OpenReadOnly(dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.Query("SELECT id, repo FROM repos ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	ret := []string{}
+	for rows.Next() {
+		var id int64
+		var repo string
+		if err := rows.Scan(&id, &repo); err != nil {
+			t.Fatal(err)
+		}
+		ret = append(ret, fmt.Sprintf("%d:%s", id, repo))
+	}
+	return ret
+}
+
+/*
+ * repos without packages (rejected, empty) have the same ids for the same input
+ */
+func TestReposIds(t *testing.T) {
+	infos := func() map[string]*RepoInfo {
+		ret := map[string]*RepoInfo{}
+		for _, repo := range []string{"core", "extra", "multilib", "kde-unstable", "community", "testing", "gnome-unstable"} {
+			ret[repo] = &RepoInfo{NAME: repo, ARCH: "x86_64"}
+		}
+		return ret
+	}
+	dir := t.TempDir()
+	var want []string
+	for i := 0; i < 5; i++ {
+		dbFile := filepath.Join(dir, fmt.Sprintf("repos%d.db", i))
+		if err := GenSqlite(dbFile, testPackagesAfter(), nil, infos()); err != nil {
+			t.Fatal(err)
+		}
+		got := dumpRepos(t, dbFile)
+		if want == nil {
+			want = got
+		} else if !reflect.DeepEqual(got, want) {
+			t.Fatalf("repos = %v, want %v", got, want)
+		}
+	}
+
+	updated := filepath.Join(dir, "updated.db")
+	if err := GenSqlite(updated, testPackagesBefore(), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UpdateSqlite(updated, testPackagesAfter(), nil, infos()); err != nil {
+		t.Fatal(err)
+	}
+	if got := dumpRepos(t, updated); !reflect.DeepEqual(got, want) {
+		t.Errorf("repos after update = %v, full generation %v", got, want)
+	}
+}
@@ -0,0 +1,172 @@
+package alpmdb
+
+import (
+	"strings"
+)
+
+/*
+ * port of pacman alpm_pkg_vercmp() (lib/libalpm/version.c)
+ * return -1 if a < b, 0 if a == b, 1 if a > b
+ * version format: [epoch:]pkgver[-pkgrel]
+ */
+func Vercmp(a string, b string) int {
+	if a == b {
+		return 0
+	}
+	epoch1, ver1, rel1, hasRel1 := parseEVR(a)
+	epoch2, ver2, rel2, hasRel2 := parseEVR(b)
+
+	ret := rpmvercmp(epoch1, epoch2)
+	if ret == 0 {
+		ret = rpmvercmp(ver1, ver2)
+		// pkgrel compared only if both versions have one, can be empty ("1.0-")
+		if ret == 0 && hasRel1 && hasRel2 {
+			ret = rpmvercmp(rel1, rel2)
+		}
+	}
+	return ret
+}
+
+/*
+ * split "epoch:version-release"
+ * epoch is "0" if not present, hasRelease false if no "-"
+ */
+func parseEVR(evr string) (epoch string, version string, release string, hasRelease bool) {
+	i := 0
+	for i < len(evr) && isDigit(evr[i]) {
+		i++
+	}
+	if i < len(evr) && evr[i] == ':' {
+		epoch = evr[:i]
+		version = evr[i+1:]
+		if epoch == "" {
+			epoch = "0"
+		}
+	} else {
+		epoch = "0"
+		version = evr
+	}
+	if pos := strings.LastIndex(version, "-"); pos > -1 {
+		release = version[pos+1:]
+		version = version[:pos]
+		hasRelease = true
+	}
+	return epoch, version, release, hasRelease
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isAlnum(c byte) bool {
+	return isDigit(c) || isAlpha(c)
+}
+
+/*
+ * compare alpha and numeric segments of two versions
+ * "1.0a" < "1.0" < "1.0.1" , "1.0" < "1.0+1"
+ */
+func rpmvercmp(a string, b string) int {
+	if a == b {
+		return 0
+	}
+	one, two := 0, 0
+	for one < len(a) && two < len(b) {
+		start1, start2 := one, two
+		for one < len(a) && !isAlnum(a[one]) {
+			one++
+		}
+		for two < len(b) && !isAlnum(b[two]) {
+			two++
+		}
+		if one >= len(a) || two >= len(b) {
+			break
+		}
+		// separators of different length: longer one is newer
+		if one-start1 != two-start2 {
+			if one-start1 < two-start2 {
+				return -1
+			}
+			return 1
+		}
+
+		ptr1, ptr2 := one, two
+		isnum := isDigit(a[ptr1])
+		if isnum {
+			for ptr1 < len(a) && isDigit(a[ptr1]) {
+				ptr1++
+			}
+			for ptr2 < len(b) && isDigit(b[ptr2]) {
+				ptr2++
+			}
+		} else {
+			for ptr1 < len(a) && isAlpha(a[ptr1]) {
+				ptr1++
+			}
+			for ptr2 < len(b) && isAlpha(b[ptr2]) {
+				ptr2++
+			}
+		}
+
+		// segments of different types: numeric is newer
+		if two == ptr2 {
+			if isnum {
+				return 1
+			}
+			return -1
+		}
+
+		seg1, seg2 := a[one:ptr1], b[two:ptr2]
+		if isnum {
+			seg1 = strings.TrimLeft(seg1, "0")
+			seg2 = strings.TrimLeft(seg2, "0")
+			if len(seg1) > len(seg2) {
+				return 1
+			}
+			if len(seg2) > len(seg1) {
+				return -1
+			}
+		}
+		if rc := strings.Compare(seg1, seg2); rc != 0 {
+			return rc
+		}
+		one, two = ptr1, ptr2
+	}
+
+	if one >= len(a) && two >= len(b) {
+		return 0
+	}
+	// "1.0alpha" < "1.0" and "1.0" < "1.0.1"
+	if (one >= len(a) && !isAlpha(b[two])) || (one < len(a) && isAlpha(a[one])) {
+		return -1
+	}
+	return 1
+}
+
+/*
+ * version satisfies a depend constraint ?
+ * ex: VersionMatch("2.38-1", ">=", "2.38") -> true
+ */
+func VersionMatch(version string, comp string, ver string) bool {
+	if comp == "" || ver == "" {
+		return true
+	}
+	cmp := Vercmp(version, ver)
+	switch comp {
+	case "=":
+		return cmp == 0
+	case ">=":
+		return cmp >= 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case "<":
+		return cmp < 0
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package alpmdb
+
+import "testing"
+
+/*
+ * cases of pacman test/util/vercmptest.sh
+ */
+var vercmpTests = []struct {
+	a    string
+	b    string
+	want int
+}{
+	// all similar length, no pkgrel
+	{"1.5.0", "1.5.0", 0},
+	{"1.5.1", "1.5.0", 1},
+	// mixed length
+	{"1.5.1", "1.5", 1},
+	// with pkgrel, simple
+	{"1.5.0-1", "1.5.0-1", 0},
+	{"1.5.0-1", "1.5.0-2", -1},
+	{"1.5.0-1", "1.5.1-1", -1},
+	{"1.5.0-2", "1.5.1-1", -1},
+	// with pkgrel, mixed lengths
+	{"1.5-1", "1.5.1-1", -1},
+	{"1.5-2", "1.5.1-1", -1},
+	{"1.5-2", "1.5.1-2", -1},
+	// mixed pkgrel inclusion
+	{"1.5", "1.5-1", 0},
+	{"1.5-1", "1.5", 0},
+	{"1.1-1", "1.1", 0},
+	{"1.0-1", "1.1", -1},
+	{"1.1-1", "1.0", 1},
+	// empty pkgrel after a trailing "-" is compared
+	{"1.0-", "1.0-1", -1},
+	{"1.0-", "1.0", 0},
+	{"1.0-", "1.0-", 0},
+	// alphanumeric versions
+	{"1.5b-1", "1.5-1", -1},
+	{"1.5b", "1.5", -1},
+	{"1.5b-1", "1.5", -1},
+	{"1.5b", "1.5.1", -1},
+	// from the manpage
+	{"1.0a", "1.0alpha", -1},
+	{"1.0alpha", "1.0b", -1},
+	{"1.0b", "1.0beta", -1},
+	{"1.0beta", "1.0rc", -1},
+	{"1.0rc", "1.0", -1},
+	// alpha-dotted versions
+	{"1.5.a", "1.5", 1},
+	{"1.5.b", "1.5.a", 1},
+	{"1.5.1", "1.5.b", 1},
+	// alpha dots and dashes
+	{"1.5.b-1", "1.5.b", 0},
+	{"1.5-1", "1.5.b", -1},
+	// same/similar content, differing separators
+	{"2.0", "2_0", 0},
+	{"2.0_a", "2_0.a", 0},
+	{"2.0a", "2.0.a", -1},
+	{"2___a", "2_a", 1},
+	// epoch included version comparisons
+	{"0:1.0", "0:1.0", 0},
+	{"0:1.0", "0:1.1", -1},
+	{"1:1.0", "0:1.0", 1},
+	{"1:1.0", "0:1.1", 1},
+	{"1:1.0", "2:1.1", -1},
+	// epoch + sometimes present pkgrel
+	{"1:1.0", "0:1.0-1", 1},
+	{"1:1.0-1", "0:1.1-1", 1},
+	// epoch included on one version
+	{"0:1.0", "1.0", 0},
+	{"0:1.1", "1.0", 1},
+	{"0:1.1", "1.1", 0},
+	{"1.0", "0:1.1", -1},
+	{"1:1.0", "1.0", 1},
+	{"1:1.1", "1.1", 1},
+	{"1.1", "1:1.1", -1},
+	// "~" is only a separator for pacman: not a pre-release as with rpm
+	{"1.0~rc1", "1.0", 1},
+	{"1.0~rc1", "1.0rc1", 1},
+	{"1.0~rc1", "1.0.rc1", 0},
+	// numeric segments: leading zeros, length
+	{"1.01", "1.1", 0},
+	{"1.10", "1.9", 1},
+	{"10.0", "9.0", 1},
+}
+
+func TestVercmp(t *testing.T) {
+	for _, tt := range vercmpTests {
+		if got := Vercmp(tt.a, tt.b); got != tt.want {
+			t.Errorf("Vercmp(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		// reverse order
+		if got := Vercmp(tt.b, tt.a); got != -tt.want {
+			t.Errorf("Vercmp(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestVersionMatch(t *testing.T) {
+	tests := []struct {
+		version string
+		comp    string
+		ver     string
+		want    bool
+	}{
+		{"2.38-1", ">=", "2.38", true},
+		{"2.37-1", ">=", "2.38", false},
+		{"2.38-1", "=", "2.38", true},
+		{"2.38-2", "=", "2.38-1", false},
+		{"1:1.0-1", ">", "2.0", true},
+		{"1.0-1", "<", "1.0a", false},
+		{"1.0-1", "<=", "1.0", true},
+		{"1.0-1", "", "", true},
+	}
+	for _, tt := range tests {
+		if got := VersionMatch(tt.version, tt.comp, tt.ver); got != tt.want {
+			t.Errorf("VersionMatch(%q, %q, %q) = %v, want %v", tt.version, tt.comp, tt.ver, got, tt.want)
+		}
+	}
+}
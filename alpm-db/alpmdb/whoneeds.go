@@ -0,0 +1,105 @@
+package alpmdb
+
+import (
+	"database/sql"
+)
+
+/*
+ * package in reverse dependencies tree
+ */
+type Needer struct {
+	id         int32
+	NAME       string
+	VERSION    string
+	REPO       string
+	TYPE       string    `json:",omitempty"` // depends, makedepends, optdepends
+	DEPEND     string    `json:",omitempty"` // depend as declared by this package
+	REQUIREDBY []*Needer `json:",omitempty"`
+}
+
+/*
+ * relations to walk backwards: table -> depend field
+ */
+func whoNeedsRelations(withMake bool, withOpt bool) map[string]string {
+	ret := map[string]string{"depends": "depend"}
+	if withMake {
+		ret["makedepends"] = "depend"
+	}
+	if withOpt {
+		ret["optdepends"] = "optdepend"
+	}
+	return ret
+}
+
+/*
+ * all packages with a relation to package id
+ * use field pkg (preferred package) filled by GenSqlite
+ */
+func findNeeders(db *sql.DB, id int32, relations map[string]string) ([]*Needer, error) {
+	ret := []*Needer{}
+	for _, table := range []string{"depends", "makedepends", "optdepends"} {
+		field, ok := relations[table]
+		if !ok {
+			continue
+		}
+		rows, err := db.Query("SELECT pkgs.id, pkgs.name, pkgs.version, repos.repo, "+table+"."+field+" FROM "+table+
+			" INNER JOIN pkgs ON pkgs.id="+table+".id LEFT JOIN repos ON repos.id=pkgs.repo WHERE "+table+".pkg=? ORDER BY pkgs.name", id)
+		if err != nil {
+			return ret, err
+		}
+		for rows.Next() {
+			n := Needer{TYPE: table}
+			var repo sql.NullString
+			if err := rows.Scan(&n.id, &n.NAME, &n.VERSION, &repo, &n.DEPEND); err != nil {
+				rows.Close()
+				return ret, err
+			}
+			n.REPO = repo.String
+			ret = append(ret, &n)
+		}
+		rows.Close()
+	}
+	return ret, nil
+}
+
+/*
+ * reverse dependencies of a package, level by level
+ * a package is displayed only once (at first level found)
+ * depth < 1 : no limit
+ * return nil if package not found
+ */
+func WhoNeeds(db *sql.DB, name string, depth int, withMake bool, withOpt bool) (*Needer, int, error) {
+	root := Needer{NAME: name}
+	var repo sql.NullString
+	err := db.QueryRow("SELECT pkgs.id, pkgs.version, repos.repo FROM pkgs LEFT JOIN repos ON repos.id=pkgs.repo WHERE pkgs.name=?", name).Scan(&root.id, &root.VERSION, &repo)
+	if err == sql.ErrNoRows {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	root.REPO = repo.String
+	relations := whoNeedsRelations(withMake, withOpt)
+
+	seen := map[int32]bool{root.id: true}
+	level := []*Needer{&root}
+	for d := 1; len(level) > 0 && (depth < 1 || d <= depth); d++ {
+		next := []*Needer{}
+		for _, parent := range level {
+			needers, err := findNeeders(db, parent.id, relations)
+			if err != nil {
+				return &root, len(seen) - 1, err
+			}
+			for _, n := range needers {
+				if seen[n.id] {
+					continue
+				}
+				seen[n.id] = true
+				parent.REQUIREDBY = append(parent.REQUIREDBY, n)
+				next = append(next, n)
+			}
+		}
+		level = next
+	}
+	return &root, len(seen) - 1, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"alpm-db/alpmdb"
+)
+
+/*
+ * unsatisfied dependencies by repo, then by packager
+ */
+func printUnsatisfied(items []alpmdb.Unsatisfied) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	repo, packager := "", ""
+	for i, u := range items {
+		newRepo := i == 0 || u.REPO != repo
+		if newRepo {
+			repo = u.REPO
+			w.Flush()
+			fmt.Println("\n::", COLOR_GREEN+repo+COLOR_NONE)
+		}
+		if newRepo || u.PACKAGER != packager {
+			packager = u.PACKAGER
+			w.Flush()
+			fmt.Println("  " + COLOR_BLUE + packager + COLOR_NONE)
+		}
+		reason := COLOR_RED + u.REASON + COLOR_NONE
+		if len(u.AVAILABLE) > 0 {
+			reason += COLOR_GRAY + " (" + strings.Join(u.AVAILABLE, ", ") + ")" + COLOR_NONE
+		}
+		fmt.Fprintf(w, "    %s\t%s\t%s\t%s\t%s\n", u.NAME, u.VERSION, u.TYPE, u.DEPEND, reason)
+	}
+	w.Flush()
+}
+
+/*
+ * ./alpm-db check [--no-make] [--no-opt]
+ * exit 1 if a dependency is not satisfied: use it before promoting a branch
+ */
+func cmdCheck(fs *flag.FlagSet, args []string) int {
+	var repos listValue
+	noMake := fs.Bool("no-make", false, "ignore makedepends")
+	noOpt := fs.Bool("no-opt", false, "ignore optdepends")
+	fs.Var(&repos, "repo", "only packages of this repo, can be repeated")
+	asJson := fs.Bool("json", false, "json output")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(positional) > 0 {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	db, err := openPacmanDb()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	defer db.Close()
+
+	items, err := alpmdb.CheckDepends(db, !*noMake, !*noOpt)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	if len(repos) > 0 {
+		filtered := []alpmdb.Unsatisfied{}
+		for _, u := range items {
+			for _, repo := range repos {
+				if u.REPO == repo {
+					filtered = append(filtered, u)
+					break
+				}
+			}
+		}
+		items = filtered
+	}
+
+	if *asJson {
+		printJson(items)
+	} else if len(items) > 0 {
+		printUnsatisfied(items)
+		pkgs := map[string]bool{}
+		for _, u := range items {
+			pkgs[u.NAME] = true
+		}
+		fmt.Println("\n=>", len(items), "unsatisfied dependencies in", len(pkgs), "packages")
+	} else {
+		fmt.Println("=> all dependencies are satisfied")
+	}
+	if len(items) > 0 {
+		return EXIT_FAILURE
+	}
+	return EXIT_OK
+}
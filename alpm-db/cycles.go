@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"alpm-db/alpmdb"
+)
+
+func printCycles(cycles []alpmdb.Cycle, title string) {
+	fmt.Println("::", COLOR_GREEN, title, COLOR_NONE, len(cycles), "cycles")
+	for i, cycle := range cycles {
+		fmt.Printf("\n%d. %s%s%s\n", i+1, COLOR_BLUE, strings.Join(cycle.PACKAGES, " "), COLOR_NONE)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+		for _, edge := range cycle.EDGES {
+			typ := ""
+			if edge.TYPE != "depends" {
+				typ = " [" + strings.TrimSuffix(edge.TYPE, "depends") + "]"
+			}
+			depend := ""
+			if edge.PROVIDED || edge.DEPEND != edge.TO {
+				depend = COLOR_GRAY + "(" + edge.DEPEND + ")" + COLOR_NONE
+			}
+			fmt.Fprintf(w, "   %s\t-> %s\t%s%s\n", edge.FROM, edge.TO, depend, typ)
+		}
+		w.Flush()
+	}
+	fmt.Println("")
+}
+
+/*
+ * ./alpm-db cycles [--runtime] [--build]
+ * strongly connected packages in depends, and in makedepends + depends
+ */
+func cmdCycles(fs *flag.FlagSet, args []string) int {
+	runtime := fs.Bool("runtime", false, "only runtime cycles (depends)")
+	build := fs.Bool("build", false, "only build cycles (makedepends with depends)")
+	asJson := fs.Bool("json", false, "json output")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(positional) > 0 {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	if !*runtime && !*build {
+		*runtime, *build = true, true
+	}
+	db, err := openPacmanDb()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	defer db.Close()
+
+	ret := []alpmdb.Cycle{}
+	for _, graph := range []struct {
+		table string
+		title string
+		used  bool
+	}{
+		{"depends", "runtime (depends)", *runtime},
+		{"makedepends", "build (makedepends and depends)", *build},
+	} {
+		if !graph.used {
+			continue
+		}
+		cycles, err := alpmdb.FindCycles(db, graph.table)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return EXIT_FAILURE
+		}
+		if *asJson {
+			ret = append(ret, cycles...)
+			continue
+		}
+		printCycles(cycles, graph.title)
+	}
+	if *asJson {
+		printJson(ret)
+	}
+	return EXIT_OK
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"alpm-db/alpmdb"
+)
+
+func printDiff(diffs []alpmdb.PackageDiff, oldBranch string, newBranch string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", "STATUS", "NAME", oldBranch, newBranch, "OLD REPO", "NEW REPO")
+	for _, d := range diffs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", d.STATUS, d.NAME, d.OLDVERSION, d.NEWVERSION, d.OLDREPO, d.NEWREPO)
+	}
+	w.Flush()
+}
+
+/*
+ * download and parse a branch in LocalDir/[arch/]branch/
+ * error if a repo is not downloaded: cached db can be obsolete
+ */
+func loadBranch(out io.Writer, urls []string, branch string, repos []string, archLayout bool, arch string) (alpmdb.Packages, error) {
+	localRepos := localReposDir(arch) + "/" + branch
+	os.MkdirAll(localRepos, os.ModeDir|0777)
+	_, infos := downloadRepos(out, alpmdb.MirrorServers(urls, branch, repos, archLayout, arch), repos, ".db", localRepos, false)
+	for _, repo := range repos {
+		if info, found := infos[repo]; !found || info.URL == "" {
+			return nil, fmt.Errorf("branch %s: %s.db not downloaded", branch, repo)
+		}
+	}
+	pkgs, err := parseRepos(out, repos, ".db", localRepos, alpmdb.PackageFilter{})
+	if err != nil {
+		return nil, err
+	}
+	pkgs, _ = pkgs.FilterArch(arch)
+	return pkgs, nil
+}
+
+/*
+ * ./alpm-db diff -b stable -b testing
+ * changes between the 2 branches
+ */
+func runDiff(out io.Writer, urls []string, branches []string, repos []string, archLayout bool, arch string) ([]alpmdb.PackageDiff, error) {
+	branchPkgs := make([]alpmdb.Packages, 2)
+	for i, branch := range branches {
+		pkgs, err := loadBranch(out, urls, branch, repos, archLayout, arch)
+		if err != nil {
+			return nil, err
+		}
+		branchPkgs[i] = pkgs
+	}
+	return alpmdb.DiffPackages(branchPkgs[0], branchPkgs[1]), nil
+}
+
+/*
+ * ./alpm-db diff -b stable -b testing [-m url]... [--arch aarch64]
+ */
+func cmdDiff(fs *flag.FlagSet, args []string) int {
+	var branches, mirrors listValue
+	fs.Var(&branches, "b", "branch, 2 times: old and new")
+	fs.Var(&mirrors, "m", "mirror url, can be repeated: next mirror if download fails")
+	archLayout := fs.Bool("archlinux", false, "use archlinux mirror format ($repo/os/$arch, $arch/$repo if not "+alpmdb.DefaultArch+")")
+	arch := fs.String("arch", alpmdb.DefaultArch, "architecture: aarch64, i686...")
+	asJson := fs.Bool("json", false, "json output")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(positional) > 0 || len(branches) != 2 {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	if len(mirrors) < 1 {
+		mirrors = listValue{url_mirror}
+	}
+	var progress io.Writer = os.Stdout
+	if *asJson {
+		// keep stdout only for json
+		progress = os.Stderr
+	}
+	diffs, err := runDiff(progress, mirrors, branches, defaultRepos, *archLayout, *arch)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	if *asJson {
+		printJson(diffs)
+		return EXIT_OK
+	}
+	fmt.Println("\n", COLOR_BLUE, "---", branches[0], "->", branches[1], COLOR_NONE)
+	printDiff(diffs, branches[0], branches[1])
+	fmt.Println("\n=>", len(diffs), "changes")
+	return EXIT_OK
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"alpm-db/alpmdb"
+)
+
+/*
+ * download all repos in localRepos
+ * servers: repo -> servers
+ * out: progress messages
+ * return false if all repos are not modified, RepoInfo.URL is empty if a repo is not downloaded
+ */
+func downloadRepos(out io.Writer, servers map[string][]string, repos []string, ext string, localRepos string, withSig bool) (bool, map[string]*alpmdb.RepoInfo) {
+	fmt.Fprintln(out, "\n", COLOR_BLUE, "--- Download repos...", COLOR_NONE)
+	tstart := time.Now() // start timer
+	ch := make(chan alpmdb.DownloadResult)
+	for _, repo := range repos {
+		go func(repo string) {
+			ch <- alpmdb.HttpGetDb(servers[repo], repo, ext, localRepos, withSig)
+		}(repo)
+	}
+	changed := false
+	infos := make(map[string]*alpmdb.RepoInfo, len(repos))
+	for range repos {
+		ret := <-ch
+		msg := ""
+		for _, err := range ret.ERRORS {
+			msg += COLOR_RED + err.Error() + COLOR_NONE + "\n"
+		}
+		if ret.URL == "" {
+			msg += ret.REPO + ext + ": " + COLOR_RED + "no mirror available" + COLOR_NONE
+		} else {
+			msg += ret.REPO + ext + " <- " + ret.URL
+			if !ret.CHANGED {
+				msg += COLOR_GRAY + " (not modified)" + COLOR_NONE
+			}
+		}
+		fmt.Fprintln(out, msg)
+		changed = changed || ret.CHANGED
+		infos[ret.REPO] = &alpmdb.RepoInfo{NAME: ret.REPO, URL: ret.URL, SIGSTATUS: alpmdb.SIG_DISABLED}
+	}
+	telapsed := time.Since(tstart)
+	fmt.Fprintln(out, "\nduration: ", COLOR_GREEN, telapsed, COLOR_NONE, "\n ")
+	return changed, infos
+}
+
+/*
+ * repos without mirror available, in order of repos
+ */
+func notDownloaded(infos map[string]*alpmdb.RepoInfo, repos []string) []string {
+	ret := []string{}
+	for _, repo := range repos {
+		if info, found := infos[repo]; !found || info.URL == "" {
+			ret = append(ret, repo)
+		}
+	}
+	return ret
+}
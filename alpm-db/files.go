@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"alpm-db/alpmdb"
+)
+
+var errNoFiles = fmt.Errorf("pacman.db without files, run: alpm-db sync --files")
+
+/*
+ * ./alpm-db owns /usr/bin/foo
+ * which packages have this file
+ */
+func runOwns(search string, regex bool) bool {
+	db, err := openPacmanDb()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	defer db.Close()
+	if !alpmdb.HasFiles(db) {
+		fmt.Fprintln(os.Stderr, errNoFiles)
+		return false
+	}
+
+	owners, err := alpmdb.Owns(db, search, regex)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, item := range owners {
+		fmt.Fprintf(w, "%s/%s\t%s\t/%s\n", item.REPO, item.NAME, item.VERSION, item.FILE)
+	}
+	w.Flush()
+	return len(owners) > 0
+}
+
+/*
+ * ./alpm-db ls pacman [search]
+ * files of a package
+ */
+func runLs(name string, search string, regex bool) bool {
+	db, err := openPacmanDb()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	defer db.Close()
+	if !alpmdb.HasFiles(db) {
+		fmt.Fprintln(os.Stderr, errNoFiles)
+		return false
+	}
+
+	files, err := alpmdb.ListFiles(db, name, search, regex)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	for _, file := range files {
+		fmt.Println(name, "/"+file)
+	}
+	return len(files) > 0
+}
+
+/*
+ * ./alpm-db owns /usr/bin/foo [--regex]
+ */
+func cmdOwns(fs *flag.FlagSet, args []string) int {
+	regex := fs.Bool("regex", false, "search is a regular expression")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	if !runOwns(positional[0], *regex) {
+		return EXIT_FAILURE
+	}
+	return EXIT_OK
+}
+
+/*
+ * ./alpm-db ls pacman [search] [--regex]
+ */
+func cmdLs(fs *flag.FlagSet, args []string) int {
+	regex := fs.Bool("regex", false, "search is a regular expression")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(positional) < 1 || len(positional) > 2 {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	search := ""
+	if len(positional) > 1 {
+		search = positional[1]
+	}
+	if !runLs(positional[0], search, *regex) {
+		return EXIT_FAILURE
+	}
+	return EXIT_OK
+}
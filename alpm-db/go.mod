@@ -1,5 +1,16 @@
 module alpm-db
 
-go 1.13
+go 1.22.0
 
-require github.com/mattn/go-sqlite3 v1.11.0
+require (
+	github.com/ProtonMail/go-crypto v1.3.0
+	github.com/klauspost/compress v1.18.0
+	github.com/mattn/go-sqlite3 v1.11.0
+	github.com/ulikunitz/xz v0.5.15
+)
+
+require (
+	github.com/cloudflare/circl v1.6.0 // indirect
+	golang.org/x/crypto v0.33.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+)
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"alpm-db/alpmdb"
+)
+
+// color of edges by relation type
+var graphColors = map[string]string{
+	"depends":     "#000000",
+	"makedepends": "#1f77b4",
+	"optdepends":  "#7f7f7f",
+}
+
+const graphMissingColor = "#d62728"
+
+func xmlEscape(value string) string {
+	var buff strings.Builder
+	xml.EscapeText(&buff, []byte(value))
+	return buff.String()
+}
+
+func dotQuote(value string) string {
+	return "\"" + strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n").Replace(value) + "\""
+}
+
+/*
+ * graphviz: dot -Tsvg graph.dot > graph.svg
+ */
+func printDot(out io.Writer, graph *alpmdb.Graph) {
+	fmt.Fprintln(out, "digraph \"alpm-db\" {")
+	fmt.Fprintln(out, "  rankdir=LR;")
+	fmt.Fprintln(out, "  node [shape=box, style=rounded, fontname=\"sans\"];")
+	for _, node := range graph.NODES {
+		label := node.NAME
+		attrs := ""
+		switch {
+		case node.MISSING:
+			attrs = ", color=\"" + graphMissingColor + "\", fontcolor=\"" + graphMissingColor + "\""
+		case node.ROOT:
+			attrs = ", style=\"rounded,bold\""
+		}
+		if !node.MISSING {
+			label += "\n" + node.VERSION + " " + node.REPO
+		}
+		fmt.Fprintf(out, "  %s [label=%s%s];\n", dotQuote(node.NAME), dotQuote(label), attrs)
+	}
+	for _, edge := range graph.EDGES {
+		attrs := "color=\"" + graphColors[edge.TYPE] + "\""
+		if edge.TYPE == "optdepends" || edge.PROVIDED {
+			attrs += ", style=dashed"
+		}
+		if edge.PROVIDED {
+			attrs += ", label=" + dotQuote(edge.DEPEND)
+		}
+		fmt.Fprintf(out, "  %s -> %s [%s];\n", dotQuote(edge.FROM), dotQuote(edge.TO), attrs)
+	}
+	fmt.Fprintln(out, "}")
+}
+
+/*
+ * graphml for gephi, yEd...
+ */
+func printGraphml(out io.Writer, graph *alpmdb.Graph) {
+	fmt.Fprintln(out, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(out, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	keys := []struct{ id, domain, name, typ string }{
+		{"version", "node", "version", "string"},
+		{"repo", "node", "repo", "string"},
+		{"root", "node", "root", "boolean"},
+		{"missing", "node", "missing", "boolean"},
+		{"type", "edge", "type", "string"},
+		{"depend", "edge", "depend", "string"},
+		{"provided", "edge", "provided", "boolean"},
+		{"color", "edge", "color", "string"},
+	}
+	for _, k := range keys {
+		fmt.Fprintf(out, "  <key id=\"%s\" for=\"%s\" attr.name=\"%s\" attr.type=\"%s\"/>\n", k.id, k.domain, k.name, k.typ)
+	}
+	fmt.Fprintln(out, `  <graph id="alpm-db" edgedefault="directed">`)
+	for _, node := range graph.NODES {
+		fmt.Fprintf(out, "    <node id=\"%s\">\n", xmlEscape(node.NAME))
+		fmt.Fprintf(out, "      <data key=\"version\">%s</data>\n", xmlEscape(node.VERSION))
+		fmt.Fprintf(out, "      <data key=\"repo\">%s</data>\n", xmlEscape(node.REPO))
+		fmt.Fprintf(out, "      <data key=\"root\">%t</data>\n", node.ROOT)
+		fmt.Fprintf(out, "      <data key=\"missing\">%t</data>\n", node.MISSING)
+		fmt.Fprintln(out, "    </node>")
+	}
+	for _, edge := range graph.EDGES {
+		fmt.Fprintf(out, "    <edge source=\"%s\" target=\"%s\">\n", xmlEscape(edge.FROM), xmlEscape(edge.TO))
+		fmt.Fprintf(out, "      <data key=\"type\">%s</data>\n", edge.TYPE)
+		fmt.Fprintf(out, "      <data key=\"depend\">%s</data>\n", xmlEscape(edge.DEPEND))
+		fmt.Fprintf(out, "      <data key=\"provided\">%t</data>\n", edge.PROVIDED)
+		fmt.Fprintf(out, "      <data key=\"color\">%s</data>\n", graphColors[edge.TYPE])
+		fmt.Fprintln(out, "    </edge>")
+	}
+	fmt.Fprintln(out, "  </graph>")
+	fmt.Fprintln(out, "</graphml>")
+}
+
+/*
+ * ./alpm-db graph base --depth 2 --format dot | dot -Tsvg > base.svg
+ */
+func cmdGraph(fs *flag.FlagSet, args []string) int {
+	reverse := fs.Bool("reverse", false, "packages that need packages (as whoneeds)")
+	depth := fs.Int("depth", 0, "max levels (0: no limit)")
+	withMake := fs.Bool("with-make", false, "with makedepends")
+	withOpt := fs.Bool("with-opt", false, "with optdepends")
+	format := fs.String("format", "dot", "output: dot, graphml, json")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(positional) < 1 || (*format != "dot" && *format != "graphml" && *format != "json") {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	db, err := openPacmanDb()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	defer db.Close()
+
+	graph, err := alpmdb.BuildGraph(db, positional, *reverse, *depth, *withMake, *withOpt)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	switch *format {
+	case "graphml":
+		printGraphml(os.Stdout, graph)
+	case "json":
+		printJson(graph)
+	default:
+		printDot(os.Stdout, graph)
+	}
+	return EXIT_OK
+}
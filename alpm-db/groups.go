@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"alpm-db/alpmdb"
+)
+
+/*
+ * ./alpm-db groups
+ */
+func cmdGroups(fs *flag.FlagSet, args []string) int {
+	asJson := fs.Bool("json", false, "json output")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(positional) > 0 {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	db, err := openPacmanDb()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	defer db.Close()
+
+	groups, err := alpmdb.Groups(db)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	if *asJson {
+		printJson(groups)
+		return EXIT_OK
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, g := range groups {
+		fmt.Fprintf(w, "%s%s%s\t%d\n", COLOR_GREEN, g.NAME, COLOR_NONE, g.PACKAGES)
+	}
+	w.Flush()
+	fmt.Println("\n=>", len(groups), "groups")
+	return EXIT_OK
+}
+
+/*
+ * ./alpm-db group base-devel
+ * as pacman -Sg
+ */
+func cmdGroup(fs *flag.FlagSet, args []string) int {
+	asJson := fs.Bool("json", false, "json output")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	db, err := openPacmanDb()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	defer db.Close()
+
+	pkgs, err := alpmdb.GroupPackages(db, positional[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	if *asJson {
+		printJson(pkgs)
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, pkg := range pkgs {
+			fmt.Fprintf(w, "%s/%s%s%s\t%s\n", pkg.REPO, COLOR_GREEN, pkg.NAME, COLOR_NONE, pkg.VERSION)
+		}
+		w.Flush()
+	}
+	if len(pkgs) < 1 {
+		if !*asJson {
+			fmt.Fprintln(os.Stderr, "group", positional[0], "not found in pacman.db")
+		}
+		return EXIT_FAILURE
+	}
+	return EXIT_OK
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"alpm-db/alpmdb"
+)
+
+/*
+ * ./alpm-db history linux61 [--branch testing]
+ * when each version first appeared and when it left its branch
+ */
+func cmdHistory(fs *flag.FlagSet, args []string) int {
+	asJson := fs.Bool("json", false, "json output")
+	branch := fs.String("branch", "", "only versions of this branch (default all synced branches)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	db, err := openPacmanDb()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	defer db.Close()
+
+	versions, err := alpmdb.History(db, positional[0], *branch)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	if len(versions) < 1 {
+		fmt.Fprintln(os.Stderr, "package", positional[0], "not found in history")
+		return EXIT_FAILURE
+	}
+	if *asJson {
+		printJson(versions)
+		return EXIT_OK
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", "VERSION", "REPO", "BRANCH", "ARCH", "BUILD DATE", "FIRST SEEN", "LEFT")
+	for _, v := range versions {
+		left := v.LEFT
+		if left == "" {
+			left = COLOR_GREEN + "current" + COLOR_NONE
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", v.VERSION, v.REPO, v.BRANCH, v.ARCH, v.BUILDDATE, v.FIRSTSEEN, left)
+	}
+	w.Flush()
+	return EXIT_OK
+}
@@ -1,19 +1,18 @@
 package main
 
 import (
-	"archive/tar"
 	"bytes"
-	"compress/gzip"
-	"database/sql"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
-	"net/http"
 	"os"
-	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
+
+	"alpm-db/alpmdb"
 )
 
 const (
@@ -24,254 +23,91 @@ const (
 	COLOR_GREEN = "\033[0;36m"
 	COLOR_RED   = "\033[38;5;124m"
 	COLOR_GRAY  = "\033[38;5;243m"
-	_VERSION    = "0.0.1"
-	LocalDir    = "/.local/share/alpm-db/repos"
+	// inside a color
+	COLOR_BOLD   = "\033[1m"
+	COLOR_NOBOLD = "\033[22m"
+	_VERSION     = "0.0.1"
+	LocalDir     = "/.local/share/alpm-db/repos"
+	LocalDb      = "/var/lib/pacman/local"
+	SyncDb       = "/var/lib/pacman/sync"
 )
 
-type tdesc map[string][]string
-
-type Package struct {
-	FILENAME    string
-	dir         string
-	id          int32
-	NAME        string
-	BASE        string `json:",omitempty"`
-	VERSION     string
-	DESC        string
-	REPO        string
-	URL         string   `json:",omitempty"`
-	LICENSE     []string `json:",omitempty"`
-	ARCH        string
-	PACKAGER    string
-	PROVIDES    []string `json:",omitempty"`
-	CONFLICTS   []string `json:",omitempty"`
-	DEPENDS     []string `json:",omitempty"`
-	OPTDEPENDS  []string `json:",omitempty"`
-	MAKEDEPENDS []string `json:",omitempty"`
-	BUILDDATE   int64
-	ISIZE       int
-	CSIZE       int
-}
-
-func getFieldString(adesc tdesc, key string) string {
-	if len(adesc[key]) < 1 {
-		return ""
-	}
-	return strings.TrimSpace(adesc[key][0])
-}
-
-func getFieldArray(adesc tdesc, key string) []string {
-	if len(adesc[key]) < 1 {
-		return make([]string, 0)
-	}
-	//TOFIX last field in linux-lts removed ???
-	for k, v := range adesc[key] { // remove descriptions
-		adesc[key][k] = strings.TrimSpace(strings.SplitN(v, ":", 2)[0])
-	}
-	return adesc[key][0:]
-}
+const (
+	EXIT_OK      = 0
+	EXIT_FAILURE = 1 // error or nothing found
+	EXIT_USAGE   = 2
+)
 
-func getFieldInt(adesc tdesc, key string) int {
-	if items, ok := adesc[key]; ok {
-		item := items[0]
-		i, err := strconv.Atoi(item)
-		if err == nil {
-			return i
-		}
-	}
-	return -1
-}
+// pacman repos order
+var defaultRepos = []string{"core", "extra", "community", "multilib"}
 
-// parse desc file content
-func (p *Package) set(desc string) bool {
-	//fields := []string{"FILENAME", "NAME", "VERSION", "URL", "DESC", "BASE"}
-
-	tmpdesc := strings.Split(desc, "\n\n")
-	/*if (p.dir == "stratis-cli-1.0.2-1/desc") {
-		fmt.Println("stratis-cli desc origin:",desc)
-	}*/
-	adesc := make(tdesc)
-	for i := range tmpdesc {
-		tmp := strings.Split(tmpdesc[i], "\n")
-		idx := strings.Replace(tmp[0], "%", "", -1)
-		if len(tmp) > 1 {
-			adesc[idx] = tmp[1:]
-		} else {
-			adesc[idx] = make([]string, 0)
-		}
-	}
-	/*for k,v := range adesc {
-		fmt.Println(k, "->", v)
-	}*/
-
-	p.VERSION = getFieldString(adesc, "VERSION")
-	p.NAME = getFieldString(adesc, "NAME")
-	/*if (p.NAME == "stratis-cli") {
-		fmt.Println("stratis-cli adesc:",adesc)
-		fmt.Println("stratis-cli:",p)
-		//os.Exit(1)
-	}*/
-	p.DESC = getFieldString(adesc, "DESC")
-	p.URL = getFieldString(adesc, "URL")
-	p.BASE = getFieldString(adesc, "BASE")
-	if p.BASE == p.NAME {
-		p.BASE = ""
+/*
+ * downloaded repos of an architecture: x86_64 in LocalDir, others in LocalDir/arch
+ */
+func localReposDir(arch string) string {
+	if arch == "" || arch == alpmdb.DefaultArch {
+		return os.Getenv("HOME") + LocalDir
 	}
-	p.PACKAGER = getFieldString(adesc, "PACKAGER")
-	p.ARCH = getFieldString(adesc, "ARCH")
-	p.FILENAME = getFieldString(adesc, "FILENAME")
-
-	p.LICENSE = getFieldArray(adesc, "LICENSE")
-	p.DEPENDS = getFieldArray(adesc, "DEPENDS")
-	p.MAKEDEPENDS = getFieldArray(adesc, "MAKEDEPENDS")
-	p.OPTDEPENDS = getFieldArray(adesc, "OPTDEPENDS")
-	p.PROVIDES = getFieldArray(adesc, "PROVIDES")
-	p.CONFLICTS = getFieldArray(adesc, "CONFLICTS")
-
-	p.BUILDDATE = int64(getFieldInt(adesc, "BUILDDATE"))
-	p.CSIZE = getFieldInt(adesc, "CSIZE")
-	p.ISIZE = getFieldInt(adesc, "ISIZE")
-
-	//fmt.Println("\n--- package struct --- \n",p,"\n---")
-	return true
+	return os.Getenv("HOME") + LocalDir + "/" + arch
 }
 
-func (p *Package) getBase() sql.NullString {
-	if len(p.BASE) > 0 && p.BASE != p.NAME {
-		return sql.NullString{
-			String: p.BASE,
-			Valid:  true,
-		}
+/*
+ * one pacman.db by architecture: packages of 2 architectures have same names
+ */
+func pacmanDbFile(arch string) string {
+	if arch == "" || arch == alpmdb.DefaultArch {
+		return "./pacman.db"
 	}
-	return sql.NullString{}
+	return "./pacman-" + arch + ".db"
 }
 
-type Packages []Package
-
 /*
- * find pakage by name
- * for replace sql too long replace package name field by field id
- * version only sql, gen : 37 seconds
- * version with FindByName() : 11 seconds !
+ * target file is newer than all repos files ?
  */
-func (p *Packages) FindByName(name string) sql.NullInt32 {
-	if strings.Contains(name, ".so") {
-		return sql.NullInt32{}
+func isUpToDate(target string, repos []string, ext string, localRepos string) bool {
+	stat, err := os.Stat(target)
+	if err != nil {
+		return false
 	}
-	for _, pkg := range *p {
-		if pkg.NAME == name {
-			return sql.NullInt32{
-				Int32: int32(pkg.id),
-				Valid: true,
-			}
+	for _, repo := range repos {
+		dbStat, err := os.Stat(localRepos + "/" + repo + ext)
+		if err != nil || !stat.ModTime().After(dbStat.ModTime()) {
+			return false
 		}
 	}
-	return sql.NullInt32{}
+	return true
 }
 
-type PackageFilter map[string]bool
-
 /*
- * source: https://gist.github.com/indraniel/1a91458984179ab4cf80
+ * parse all repos files (in pacman order)
+ * out: progress messages
  */
-func ExtractTarGz(gzipStream io.Reader, pkgs Packages, repo string, filters PackageFilter) Packages {
-	if len(filters) > 0 && len(pkgs) == len(filters) {
-		return pkgs
-	}
-	uncompressedStream, err := gzip.NewReader(gzipStream)
-	if err != nil {
-		log.Fatal("ExtractTarGz: NewReader failed")
-	}
-
-	tarReader := tar.NewReader(uncompressedStream)
-
-	for true {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			break
-		}
+func parseRepos(out io.Writer, repos []string, ext string, localRepos string, filters alpmdb.PackageFilter) (alpmdb.Packages, error) {
+	fmt.Fprintln(out, "\n", COLOR_BLUE, "--- Parse files...", COLOR_NONE)
+	var pkgs alpmdb.Packages
+	tstart := time.Now() // start timer
 
+	for _, repo := range repos {
+		nb := len(pkgs)
+		fmt.Fprintln(out, "::", repo, "...")
+		f, err := os.Open(localRepos + "/" + repo + ext)
 		if err != nil {
-			fmt.Println("err", err.Error())
-			log.Fatalf("ExtractTarGz: Next() failed: %s", err.Error())
+			return nil, err
 		}
-
-		switch header.Typeflag {
-		case tar.TypeDir:
-			/*fmt.Println("::dir:",header.Name)*/
-		case tar.TypeReg:
-
-			buf := new(bytes.Buffer)
-
-			if nb, err := buf.ReadFrom(tarReader); err != nil {
-				if err != io.EOF {
-					nb = nb + 1
-					fmt.Println("error", err.Error())
-					log.Fatalf("ExtractTarGz:  failed: %s", err.Error())
-				}
-			}
-
-			pkg := Package{
-				dir:  header.Name,
-				REPO: repo,
-				id:   int32(len(pkgs)) + 1,
-			}
-			if pkg.set(string(buf.Bytes())) {
-				if len(filters) > 0 {
-					if _, found := filters[pkg.NAME]; !found {
-						continue
-					}
-					//pkgs = append(pkgs, pkg)
-				}
-				pkgs = append(pkgs, pkg)
-				if len(filters) == 1 && len(pkgs) > 0 {
-					return pkgs
-				}
-			}
-		default:
-			fmt.Println("error def", header.Typeflag, header.Name)
-			log.Fatalf(
-				"ExtractTarGz: uknown type: %s in %s",
-				header.Typeflag,
-				header.Name)
+		pkgs, err = alpmdb.ExtractTarGz(f, pkgs, repo, filters)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", repo+ext, err)
 		}
+		fmt.Fprintln(out, repo, len(pkgs)-nb, "packages")
 	}
-	return pkgs
-}
-
-func httpGetDb(url string, repo string, localDir string, ch chan<- string) {
-	url = url + "/" + repo + "/x86_64/" + repo + ".db"
-	if getParam("--arch") {
-		url = url + "/" + repo + "/os/x86_64/" + repo + ".db"
-	}
-	localDir = localDir + "/" + repo + ".db"
-	client := http.Client{Timeout: time.Duration(25) * time.Second}
-	//fmt.Println(url + "...")
-	resp, err := client.Get(url)
-	if err != nil {
-		ch <- err.Error()
-		return
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode > 399 {
-		ch <- fmt.Sprintf("http error: %s: %v", url, resp.StatusCode)
-		return
-	}
-	out, err := os.Create(localDir)
-	if err != nil {
-		ch <- fmt.Sprintf("os error: %s: %v", url, err)
-		return
-	}
-	defer out.Close()
-	_, err = io.Copy(out, resp.Body)
-	if err != nil {
-		ch <- fmt.Sprintf("io error: %s: %v", url, err)
-		return
-	}
-	ch <- url
+	telapsed := time.Since(tstart)
+	fmt.Fprintln(out, "\nduration: ", COLOR_GREEN, telapsed, COLOR_NONE, "\n ")
+	fmt.Fprintln(out, "\n=>", len(pkgs), "packages")
+	return pkgs, nil
 }
 
-func datasToJson(pkgs Packages) string {
+func datasToJson(pkgs alpmdb.Packages) string {
 
 	buff := &bytes.Buffer{}
 	enc := json.NewEncoder(buff)
@@ -282,7 +118,7 @@ func datasToJson(pkgs Packages) string {
 	return strings.ReplaceAll(buff.String(), "},{", "},\n{")
 }
 
-func genJson(pkgs Packages) {
+func genJson(pkgs alpmdb.Packages) {
 
 	fmt.Println("\n", COLOR_BLUE, "--- Json génération...", COLOR_NONE)
 	// always in os.Getenv("HOME")+LocalDir ?
@@ -301,154 +137,189 @@ func genJson(pkgs Packages) {
 }
 
 /*
- * parameter console is present ?
+ * repeated flag: -m url1 -m url2
  */
-func getParam(key string) bool {
-	for _, arg := range os.Args[1:] {
-		if arg == key {
-			return true
-		}
+type listValue []string
+
+func (l *listValue) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+/*
+ * flag with optional value: --config or --config=file
+ */
+type optionalValue struct {
+	value string
+	set   bool
+}
+
+func (o *optionalValue) String() string {
+	return o.value
+}
+
+func (o *optionalValue) Set(value string) error {
+	o.set = true
+	if value != "true" {
+		o.value = value
 	}
-	return false
+	return nil
+}
+
+func (o *optionalValue) IsBoolFlag() bool {
+	return true
 }
 
 /*
- * get console value after a parameter
+ * flags can be before or after arguments: whoneeds glibc --depth 2
+ * "--" ends flags
  */
-func getParamValue(key string, def string) string {
-	for i, arg := range os.Args[1:] {
-		if arg == key {
-			if len(os.Args) > i+2 {
-				return os.Args[i+2]
-			} else {
-				return def
-			}
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
 		}
+		rest := fs.Args()
+		if len(rest) < 1 {
+			return positional, nil
+		}
+		if i := len(args) - len(rest); i > 0 && args[i-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
 	}
-	return def
 }
 
 /*
- * get console values after a parameter
+ * exit code of a flags error, -h is not an error
  */
-func getParamValues(key string) (ret PackageFilter) {
-	ret = make(PackageFilter, len(os.Args)-2)
-	for i, arg := range os.Args[1:] {
-		if arg == key {
-			j := 2
-			for len(os.Args) > i+j {
-				nextv := os.Args[i+j]
-				if strings.HasPrefix(nextv, "-") {
-					return ret
-				}
-				ret[nextv] = true
-				j = j + 1
-			}
-		}
+func usageError(err error) int {
+	if err == flag.ErrHelp {
+		return EXIT_OK
 	}
-	return ret
+	return EXIT_USAGE
 }
 
-func main() {
-	os.MkdirAll(os.Getenv("HOME")+LocalDir, os.ModeDir|0777)
+type command struct {
+	name  string
+	usage string // arguments
+	help  string
+	run   func(fs *flag.FlagSet, args []string) int
+	db    bool // reads pacman.db: flag --arch
+}
 
-	a := getParamValue("-h", "x")
-	fmt.Println(os.Args, "-h", "=>", a)
-	b := getParam("--help")
-	fmt.Println(os.Args, "--help", "=>", b)
-
-	packagesFilter := getParamValues("-p")
-	fmt.Println("packages:", packagesFilter)
-	//os.Exit(0)
-
-	if getParam("-h") || getParam("--help") {
-		fmt.Println("")
-		fmt.Println(COLOR_GRAY, "[ENV=\"\"]", COLOR_NONE, "./alpm-db [--json] [--sql] [-m mirrorurl] [--arch] [-b branch] [-q sql] [-p [values]]")
-		fmt.Println("  --sql    : create sqlite3 ./pacman.db")
-		fmt.Println("  --json   : create ./pacman.json")
-		fmt.Println("  -b testing      : change branch (stable default)")
-		fmt.Println("  -m \"https://xx\" : use different mirror (\"local\": use local pacman db)")
-		fmt.Println("  --arch          : use archlinux mirror format")
-		fmt.Println("  -p [packages]   : find and display json of this packages")
-		fmt.Println("")
-		fmt.Println("  -q \"SELECT * FROM pkgs\" : run sqlite command (pacman.db)")
-		//TODO format output ??
-		fmt.Println("")
-		fmt.Println("Downloads in :", os.Getenv("HOME")+LocalDir)
-		os.Exit(0)
-	}
+var commands = []command{
+	{"sync", "[flags]", "download repos and generate ./pacman.db", cmdSync, false},
+	{"query", "[flags] <sql>", "run a read only sql request on ./pacman.db (sql function vercmp(a,b))", cmdQuery, true},
+	{"info", "", "tables of ./pacman.db and packagers", cmdInfo, true},
+	{"search", "[flags] <term>...", "packages with all terms in name, description or provides", cmdSearch, true},
+	{"show", "[flags] <package>", "package details", cmdShow, true},
+	{"groups", "[flags]", "groups and count of packages", cmdGroups, true},
+	{"group", "[flags] <group>", "packages of a group", cmdGroup, true},
+	{"deps", "[flags] <package>", "dependencies of a package and packages selected as pacman", cmdDeps, true},
+	{"check", "[flags]", "dependencies not satisfied by synced repos, exit 1 if any", cmdCheck, true},
+	{"cycles", "[flags]", "dependency cycles: runtime and build", cmdCycles, true},
+	{"graph", "[flags] <package>...", "dependency graph as dot, graphml or json", cmdGraph, true},
+	{"whoneeds", "[flags] <package>", "packages affected by package", cmdWhoNeeds, true},
+	{"soname", "[flags] <lib>", "packages that provide a library (libssl.so) and packages that need it", cmdSoname, true},
+	{"owns", "[flags] <path|name|glob>", "packages with this file (pacman.db with --files)", cmdOwns, true},
+	{"ls", "[flags] <package> [path|name|glob]", "files of package (pacman.db with --files)", cmdLs, true},
+	{"history", "[flags] <package>", "versions of a package seen by syncs", cmdHistory, true},
+	{"export", "[flags] [packages...]", "json of packages in downloaded repos (no download)", cmdExport, false},
+	{"diff", "[flags]", "packages changes between 2 branches", cmdDiff, false},
+	{"serve", "[flags]", "http json api on ./pacman.db", cmdServe, true},
+	{"vercmp", "<version1> <version2>", "compare versions as pacman (-1, 0, 1)", cmdVercmp, false},
+}
 
-	arg := getParamValue("-q", "")
-	if arg != "" {
-		//RequestSql(os.Args[i+2])
-		RunSql("", arg)
-		os.Exit(0)
+/*
+ * ./alpm-db vercmp 1.0-1 1.0-2
+ */
+func cmdVercmp(fs *flag.FlagSet, args []string) int {
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
 	}
-	if getParam("-i") || getParam("--info") {
-		SqlTableStruct("pkgs")
-		SqlTableStruct("depends")
-		SqlTableStruct("makedepends")
-		SqlTableStruct("packagers")
-		SqlTableStruct("repos")
-
-		RunSql("Mainteners", "SELECT count(name) as 'packages', packagers.packager, packagers.id FROM pkgs LEFT JOIN packagers ON pkgs.packager=packagers.id GROUP BY packagers.id HAVING packagers.packager LIKE '%manjaro%' order by packages DESC")
-		os.Exit(0)
+	if len(positional) != 2 {
+		fs.Usage()
+		return EXIT_USAGE
 	}
+	fmt.Println(alpmdb.Vercmp(positional[0], positional[1]))
+	return EXIT_OK
+}
 
-	LocalRepos := "/var/lib/pacman/sync"
-	repos := []string{"core", "extra", "community", "multilib"}
-
-	url := getParamValue("-m", url_mirror)
-	branch := getParamValue("-b", "stable")
-
-	if url != "local" {
-		LocalRepos = os.Getenv("HOME") + LocalDir
-		fmt.Println("\n", COLOR_BLUE, "--- Download repos...", COLOR_NONE)
-		tstart := time.Now() // start timer
-		ch := make(chan string)
-		for _, repo := range repos {
-			os.Remove(LocalRepos + "/" + repo + ".db")
-			println(LocalRepos + "/" + repo + ".db")
-			go httpGetDb(url+"/"+branch, repo, LocalRepos, ch)
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
 		}
-		for range repos {
-			fmt.Println(<-ch)
-		}
-		telapsed := time.Since(tstart)
-		fmt.Println("\nduration: ", COLOR_GREEN, telapsed, COLOR_NONE, "\n ")
 	}
+	return nil
+}
 
-	fmt.Println("\n", COLOR_BLUE, "--- Parse files...", COLOR_NONE)
-	var pkgs Packages
-	tstart := time.Now() // start timer
+func newFlagSet(cmd command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	if cmd.db {
+		fs.StringVar(&pacmanArch, "arch", alpmdb.DefaultArch, "architecture: use ./pacman-<arch>.db generated by sync --arch")
+	}
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: alpm-db", cmd.name, cmd.usage)
+		fmt.Fprintln(fs.Output(), " ", cmd.help)
+		fs.PrintDefaults()
+	}
+	return fs
+}
 
-	for _, repo := range repos {
-		nb := len(pkgs)
-		fmt.Println("::", repo, "...")
-		f, err := os.Open(LocalRepos + "/" + repo + ".db")
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
-		defer f.Close()
-		pkgs = ExtractTarGz(f, pkgs, repo, packagesFilter)
-		fmt.Println(repo, len(pkgs)-nb, "packages")
+func usage() {
+	fmt.Println("usage: alpm-db <command> [flags] [arguments]")
+	fmt.Println("")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s\t%s\n", cmd.name, cmd.help)
 	}
-	telapsed := time.Since(tstart)
-	fmt.Println("\nduration: ", COLOR_GREEN, telapsed, COLOR_NONE, "\n ")
-	fmt.Println("\n=>", len(pkgs), "packages")
+	w.Flush()
+	fmt.Println("")
+	fmt.Println("alpm-db <command> -h : flags of command")
+	fmt.Println("--arch <arch> : ./pacman-<arch>.db generated by alpm-db sync --arch <arch>")
+	fmt.Println("exit codes: 0 ok, 1 error or nothing found, 2 bad usage")
+	fmt.Println("Downloads in :", os.Getenv("HOME")+LocalDir)
+}
 
-	if getParam("--json") {
-		genJson(pkgs)
+func main() {
+	alpmdb.Output = os.Stdout
+	os.MkdirAll(os.Getenv("HOME")+LocalDir, os.ModeDir|0777)
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(EXIT_USAGE)
 	}
-	if getParam("--sql") {
-		GenSqlite(pkgs)
+	name := os.Args[1]
+	switch name {
+	case "-h", "--help", "help":
+		if len(os.Args) > 2 {
+			if cmd := findCommand(os.Args[2]); cmd != nil {
+				fs := newFlagSet(*cmd)
+				fs.SetOutput(os.Stdout)
+				os.Exit(cmd.run(fs, []string{"-h"}))
+			}
+		}
+		usage()
+		os.Exit(EXIT_OK)
+	case "--version", "version":
+		fmt.Println("alpm-db", _VERSION)
+		os.Exit(EXIT_OK)
 	}
-
-	if len(packagesFilter) > 0 {
-		fmt.Println(datasToJson(pkgs))
+	if cmd := findCommand(name); cmd != nil {
+		os.Exit(cmd.run(newFlagSet(*cmd), os.Args[2:]))
 	}
-
+	fmt.Fprintln(os.Stderr, "alpm-db: unknown command", name)
+	fmt.Fprintln(os.Stderr, "alpm-db -h : list of commands")
+	os.Exit(EXIT_USAGE)
 }
 
 /*
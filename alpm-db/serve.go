@@ -0,0 +1,253 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"alpm-db/alpmdb"
+)
+
+const (
+	SERVE_PERPAGE     = 50
+	SERVE_MAX_PERPAGE = 500
+)
+
+// a page of /api/packages
+type packagesPage struct {
+	TOTAL    int
+	PAGE     int
+	PERPAGE  int
+	PACKAGES alpmdb.Packages
+}
+
+type apiError struct {
+	ERROR string
+}
+
+func writeJson(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err string) {
+	writeJson(w, status, apiError{ERROR: err})
+}
+
+func queryInt(r *http.Request, key string, value int) (int, error) {
+	if param := r.URL.Query().Get(key); param != "" {
+		i, err := strconv.Atoi(param)
+		if err != nil {
+			return value, fmt.Errorf("invalid %s: %s", key, param)
+		}
+		return i, nil
+	}
+	return value, nil
+}
+
+func queryBool(r *http.Request, key string) bool {
+	b, _ := strconv.ParseBool(r.URL.Query().Get(key))
+	return b
+}
+
+/*
+ * pacman.db is opened by request: sync can regenerate it while server runs
+ */
+func withDb(handler func(w http.ResponseWriter, r *http.Request, db *sql.DB)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db, err := openPacmanDb()
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		defer db.Close()
+		handler(w, r, db)
+	}
+}
+
+/*
+ * GET /api/packages?q=text&repo=core&sort=name&order=desc&page=1&per_page=50
+ */
+func apiPackages(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	page, err := queryInt(r, "page", 1)
+	if err != nil || page < 1 {
+		writeError(w, http.StatusBadRequest, "invalid page: "+r.URL.Query().Get("page"))
+		return
+	}
+	perPage, err := queryInt(r, "per_page", SERVE_PERPAGE)
+	if err != nil || perPage < 1 || perPage > SERVE_MAX_PERPAGE {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid per_page: %s (1..%d)", r.URL.Query().Get("per_page"), SERVE_MAX_PERPAGE))
+		return
+	}
+	order := r.URL.Query().Get("order")
+	if order != "" && order != "asc" && order != "desc" {
+		writeError(w, http.StatusBadRequest, "invalid order: "+order+" (asc, desc)")
+		return
+	}
+
+	options := alpmdb.ListOptions{
+		SEARCH: r.URL.Query().Get("q"),
+		REPO:   r.URL.Query().Get("repo"),
+		SORT:   r.URL.Query().Get("sort"),
+		DESC:   order == "desc",
+		OFFSET: (page - 1) * perPage,
+		LIMIT:  perPage,
+	}
+	pkgs, total, err := alpmdb.ListPackages(db, options)
+	if err != nil {
+		// only invalid sort, other options are checked
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJson(w, http.StatusOK, packagesPage{TOTAL: total, PAGE: page, PERPAGE: perPage, PACKAGES: pkgs})
+}
+
+/*
+ * GET /api/packages/{name}
+ */
+func apiPackage(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	pkg, err := alpmdb.GetPackage(db, r.PathValue("name"))
+	switch {
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	case pkg == nil:
+		writeError(w, http.StatusNotFound, "package not found: "+r.PathValue("name"))
+	default:
+		writeJson(w, http.StatusOK, pkg)
+	}
+}
+
+/*
+ * GET /api/packages/{name}/depends?make=1&opt=1
+ */
+func apiDepends(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	deps, err := alpmdb.Depends(db, r.PathValue("name"), queryBool(r, "make"), queryBool(r, "opt"))
+	switch {
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	case deps == nil:
+		writeError(w, http.StatusNotFound, "package not found: "+r.PathValue("name"))
+	default:
+		writeJson(w, http.StatusOK, deps)
+	}
+}
+
+/*
+ * GET /api/packages/{name}/whoneeds?depth=1&make=1&opt=1
+ */
+func apiWhoNeeds(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	depth, err := queryInt(r, "depth", 1)
+	if err != nil || depth < 0 {
+		writeError(w, http.StatusBadRequest, "invalid depth: "+r.URL.Query().Get("depth"))
+		return
+	}
+	root, _, err := alpmdb.WhoNeeds(db, r.PathValue("name"), depth, queryBool(r, "make"), queryBool(r, "opt"))
+	switch {
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	case root == nil:
+		writeError(w, http.StatusNotFound, "package not found: "+r.PathValue("name"))
+	default:
+		writeJson(w, http.StatusOK, root)
+	}
+}
+
+/*
+ * GET /api/repos
+ */
+func apiRepos(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	repos, err := alpmdb.Repos(db)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJson(w, http.StatusOK, repos)
+}
+
+/*
+ * GET /api/packagers
+ */
+func apiPackagers(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	packagers, err := alpmdb.Packagers(db)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJson(w, http.StatusOK, packagers)
+}
+
+/*
+ * GET /api/groups
+ */
+func apiGroups(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	groups, err := alpmdb.Groups(db)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJson(w, http.StatusOK, groups)
+}
+
+/*
+ * GET /api/groups/{name}
+ */
+func apiGroup(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	pkgs, err := alpmdb.GroupPackages(db, r.PathValue("name"))
+	switch {
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	case len(pkgs) < 1:
+		writeError(w, http.StatusNotFound, "group not found: "+r.PathValue("name"))
+	default:
+		writeJson(w, http.StatusOK, pkgs)
+	}
+}
+
+/*
+ * ./alpm-db serve --listen :8080
+ * json api on ./pacman.db
+ */
+func cmdServe(fs *flag.FlagSet, args []string) int {
+	listen := fs.String("listen", ":8080", "http `address`")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(positional) > 0 {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	db, err := openPacmanDb()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	db.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/packages", withDb(apiPackages))
+	mux.HandleFunc("GET /api/packages/{name}", withDb(apiPackage))
+	mux.HandleFunc("GET /api/packages/{name}/depends", withDb(apiDepends))
+	mux.HandleFunc("GET /api/packages/{name}/whoneeds", withDb(apiWhoNeeds))
+	mux.HandleFunc("GET /api/repos", withDb(apiRepos))
+	mux.HandleFunc("GET /api/packagers", withDb(apiPackagers))
+	mux.HandleFunc("GET /api/groups", withDb(apiGroups))
+	mux.HandleFunc("GET /api/groups/{name}", withDb(apiGroup))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+	})
+
+	fmt.Println("::", COLOR_GREEN, "listen", *listen, COLOR_NONE)
+	if err := http.ListenAndServe(*listen, mux); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	return EXIT_OK
+}
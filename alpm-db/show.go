@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"alpm-db/alpmdb"
+)
+
+func printJson(v interface{}) {
+	buff := &bytes.Buffer{}
+	enc := json.NewEncoder(buff)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(buff.String())
+}
+
+/*
+ * ./alpm-db search term... [--branch testing]
+ * as pacman -Ss, all terms as prefix, best matches first, in all synced branches
+ */
+func cmdSearch(fs *flag.FlagSet, args []string) int {
+	asJson := fs.Bool("json", false, "json output")
+	branch := fs.String("branch", "", "only packages of this branch (default all synced branches)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(positional) < 1 {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	db, err := openPacmanDb()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	defer db.Close()
+
+	results, err := alpmdb.Search(db, positional, *branch, COLOR_BOLD, COLOR_NOBOLD)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	if *asJson {
+		printJson(results)
+	} else {
+		for _, r := range results {
+			branch := ""
+			if r.BRANCH != "" {
+				branch = COLOR_BLUE + "[" + r.BRANCH + "]" + COLOR_NONE
+			}
+			fmt.Println(r.REPO+"/"+COLOR_GREEN+r.SNIPPETNAME+COLOR_NONE, r.VERSION, branch)
+			fmt.Println("    " + r.SNIPPET)
+		}
+	}
+	if len(results) < 1 {
+		return EXIT_FAILURE
+	}
+	return EXIT_OK
+}
+
+/*
+ * ./alpm-db show pacman
+ * as pacman -Si
+ */
+func cmdShow(fs *flag.FlagSet, args []string) int {
+	asJson := fs.Bool("json", false, "json output")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	db, err := openPacmanDb()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	defer db.Close()
+
+	pkg, err := alpmdb.GetPackage(db, positional[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	if pkg == nil {
+		fmt.Fprintln(os.Stderr, "package", positional[0], "not found in pacman.db")
+		return EXIT_FAILURE
+	}
+	if *asJson {
+		printJson(pkg)
+		return EXIT_OK
+	}
+
+	list := func(values []string) string {
+		if len(values) < 1 {
+			return "None"
+		}
+		return strings.Join(values, "  ")
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, "Repository\t: %s\n", pkg.REPO)
+	fmt.Fprintf(w, "Name\t: %s%s%s\n", COLOR_GREEN, pkg.NAME, COLOR_NONE)
+	fmt.Fprintf(w, "Version\t: %s\n", pkg.VERSION)
+	fmt.Fprintf(w, "Description\t: %s\n", pkg.DESC)
+	fmt.Fprintf(w, "URL\t: %s\n", pkg.URL)
+	fmt.Fprintf(w, "Licenses\t: %s\n", list(pkg.LICENSE))
+	fmt.Fprintf(w, "Groups\t: %s\n", list(pkg.GROUPS))
+	fmt.Fprintf(w, "Provides\t: %s\n", list(pkg.PROVIDES))
+	fmt.Fprintf(w, "Depends On\t: %s\n", list(pkg.DEPENDS))
+	fmt.Fprintf(w, "Optional Deps\t: %s\n", list(pkg.OPTDEPENDS))
+	fmt.Fprintf(w, "Make Deps\t: %s\n", list(pkg.MAKEDEPENDS))
+	fmt.Fprintf(w, "Check Deps\t: %s\n", list(pkg.CHECKDEPENDS))
+	fmt.Fprintf(w, "Conflicts With\t: %s\n", list(pkg.CONFLICTS))
+	fmt.Fprintf(w, "Replaces\t: %s\n", list(pkg.REPLACES))
+	fmt.Fprintf(w, "Download Size\t: %d\n", pkg.CSIZE)
+	fmt.Fprintf(w, "Installed Size\t: %d\n", pkg.ISIZE)
+	fmt.Fprintf(w, "Packager\t: %s\n", pkg.PACKAGER)
+	fmt.Fprintf(w, "Build Date\t: %s\n", time.Unix(pkg.BUILDDATE, 0).UTC().Format("2006-01-02 15:04:05"))
+	signature := "None"
+	if pkg.PGPSIG != "" {
+		signature = "Yes"
+	}
+	fmt.Fprintf(w, "MD5 Sum\t: %s\n", pkg.MD5SUM)
+	fmt.Fprintf(w, "SHA-256 Sum\t: %s\n", pkg.SHA256SUM)
+	fmt.Fprintf(w, "Signature\t: %s\n", signature)
+	if len(pkg.XDATA) > 0 {
+		fmt.Fprintf(w, "Extended Data\t: %s\n", list(pkg.XDATA))
+	}
+	w.Flush()
+	return EXIT_OK
+}
+
+/*
+ * ./alpm-db deps pacman [--make] [--opt]
+ */
+func cmdDeps(fs *flag.FlagSet, args []string) int {
+	withMake := fs.Bool("make", false, "with makedepends")
+	withOpt := fs.Bool("opt", false, "with optdepends")
+	asJson := fs.Bool("json", false, "json output")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	db, err := openPacmanDb()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	defer db.Close()
+
+	deps, err := alpmdb.Depends(db, positional[0], *withMake, *withOpt)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	if deps == nil {
+		fmt.Fprintln(os.Stderr, "package", positional[0], "not found in pacman.db")
+		return EXIT_FAILURE
+	}
+	if *asJson {
+		printJson(deps)
+		return EXIT_OK
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, d := range deps {
+		typ := ""
+		if d.TYPE != "depends" {
+			typ = "[" + strings.TrimSuffix(d.TYPE, "depends") + "]"
+		}
+		if d.NAME == "" {
+			fmt.Fprintf(w, "%s\t%s\t%snot satisfied%s\n", d.DEPEND, typ, COLOR_RED, COLOR_NONE)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s/%s %s\n", d.DEPEND, typ, d.REPO, d.NAME, d.VERSION)
+	}
+	w.Flush()
+	return EXIT_OK
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"alpm-db/alpmdb"
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+/*
+ * verify all dbs before parse, rejected dbs are removed from cache
+ * levels: repo -> Never, Optional, Required
+ * return repos to parse
+ */
+func verifyRepos(infos map[string]*alpmdb.RepoInfo, repos []string, ext string, localRepos string, levels map[string]string, keyring openpgp.EntityList) []string {
+	fmt.Println("\n", COLOR_BLUE, "--- Verify signatures...", COLOR_NONE)
+	ret := make([]string, 0, len(repos))
+	for _, repo := range repos {
+		info := infos[repo]
+		info.SIGLEVEL = levels[repo]
+		if info.SIGLEVEL == alpmdb.SIGLEVEL_NEVER {
+			info.SIGSTATUS = alpmdb.SIG_DISABLED
+			ret = append(ret, repo)
+			continue
+		}
+		dbFile := localRepos + "/" + repo + ext
+		info.SIGSTATUS, info.SIGKEY = alpmdb.VerifyDb(dbFile, keyring)
+		if alpmdb.SigRejected(info.SIGLEVEL, info.SIGSTATUS) {
+			fmt.Println(repo+ext, COLOR_RED+info.SIGSTATUS, "signature: rejected"+COLOR_NONE)
+			if localRepos != SyncDb {
+				os.Remove(dbFile)
+				os.Remove(dbFile + ".meta")
+			}
+			continue
+		}
+		fmt.Println(repo+ext, COLOR_GREEN+info.SIGSTATUS+COLOR_NONE, info.SIGKEY)
+		ret = append(ret, repo)
+	}
+	return ret
+}
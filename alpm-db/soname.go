@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"alpm-db/alpmdb"
+)
+
+/*
+ * ./alpm-db soname libssl.so
+ * packages that provide a library and packages that need it
+ */
+func cmdSoname(fs *flag.FlagSet, args []string) int {
+	asJson := fs.Bool("json", false, "json output")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	db, err := openPacmanDb()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	defer db.Close()
+
+	index, err := alpmdb.SonameInfo(db, positional[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	if *asJson {
+		printJson(index)
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Println("::", COLOR_GREEN, index.SONAME, COLOR_NONE, "providers")
+		for _, p := range index.PROVIDERS {
+			provided := index.SONAME
+			if p.SOVERSION != "" {
+				provided += "=" + p.SOVERSION
+			}
+			if p.ABI != "" {
+				provided += "-" + p.ABI
+			}
+			fmt.Fprintf(w, "  %s/%s\t%s\t%s\n", p.REPO, p.NAME, p.VERSION, provided)
+		}
+		w.Flush()
+		fmt.Println("\n::", COLOR_GREEN, index.SONAME, COLOR_NONE, "consumers")
+		for _, c := range index.CONSUMERS {
+			provider := COLOR_RED + "not satisfied" + COLOR_NONE
+			if c.PROVIDER != "" {
+				provider = "-> " + c.PROVIDER
+			}
+			typ := ""
+			if c.TYPE != "depends" {
+				typ = " [" + strings.TrimSuffix(c.TYPE, "depends") + "]"
+			}
+			fmt.Fprintf(w, "  %s/%s\t%s\t%s\t%s%s\n", c.REPO, c.NAME, c.VERSION, c.DEPEND, provider, typ)
+		}
+		w.Flush()
+	}
+	if len(index.PROVIDERS) < 1 && len(index.CONSUMERS) < 1 {
+		return EXIT_FAILURE
+	}
+	return EXIT_OK
+}
@@ -3,476 +3,269 @@ package main
 import (
 	"bytes"
 	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
-	"log"
 	"os"
-	"os/exec"
-	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"alpm-db/alpmdb"
 )
 
-func moveFile(sourcePath, destPath string) error {
-	// canèt use "rename" in /tmp
-	inputFile, err := os.Open(sourcePath)
-	if err != nil {
-		return fmt.Errorf("Couldn't open source file: %s", err)
-	}
-	outputFile, err := os.Create(destPath)
-	if err != nil {
-		inputFile.Close()
-		return fmt.Errorf("Couldn't open dest file: %s", err)
-	}
-	defer outputFile.Close()
-	_, err = io.Copy(outputFile, inputFile)
-	inputFile.Close()
-	if err != nil {
-		return fmt.Errorf("Writing to output file failed: %s", err)
-	}
-	// The copy was successful, so now delete the original file
-	err = os.Remove(sourcePath)
-	if err != nil {
-		return fmt.Errorf("Failed removing original file: %s", err)
-	}
-	return nil
-}
+// output formats of a sql request
+var sqlFormats = []string{"table", "csv", "tsv", "json", "ndjson", "markdown"}
+
+// architecture of pacman.db to read (flag --arch)
+var pacmanArch = alpmdb.DefaultArch
 
-func getSepDepend(dep string) string {
-	seps := []string{">=", "<=", "=", "<", ">"}
-	for _, sep := range seps {
-		if strings.Contains(dep, sep) {
-			return sep
+/*
+ * open ./pacman.db (or ./pacman-<arch>.db) read only
+ */
+func openPacmanDb() (*sql.DB, error) {
+	dbFile := pacmanDbFile(pacmanArch)
+	db, err := alpmdb.OpenReadOnly(dbFile)
+	if err != nil {
+		if dbFile == pacmanDbFile("") {
+			return nil, fmt.Errorf("%s not found, run: alpm-db sync", dbFile)
 		}
+		return nil, fmt.Errorf("%s not found, run: alpm-db sync --arch %s", dbFile, pacmanArch)
 	}
-	return ""
+	return db, nil
 }
 
-func GenSqlite(pkgs Packages) {
-	fmt.Println("\n", COLOR_BLUE, "--- sqlite génération...", COLOR_NONE)
-	os.Remove("./pacman.db")
-	os.Remove("/tmp/pacman.db")
-	//defer os.Rename("/tmp/pacman.db", "./pacman.db")
-	db, err := sql.Open("sqlite3", "/tmp/pacman.db")
+/*
+ * all rows of a request, values are nil, int64, float64, string or time.Time
+ */
+func queryRows(db *sql.DB, requestStr string) ([]string, [][]interface{}, error) {
+	rows, err := db.Query(requestStr)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, err
 	}
-	defer db.Close()
-
-	tstart := time.Now() // start timer
-	/* pkgs: UNIQUE(name)
-	 * ignore duplicate as pacman (by order of repos)
-	 */
-	stmt, _ := db.Prepare("CREATE TABLE IF NOT EXISTS pkgs (id INTEGER PRIMARY KEY, name TEXT UNIQUE NOT NULL, base TEXT DEFAULT NULL, version TEXT NOT NULL, repo INTEGER, desc TEXT, url TEXT, builddate TIME, csize INTEGER, isize INTEGER, packager INTEGER);")
-	stmt.Exec()
-	stmt, _ = db.Prepare("CREATE TABLE IF NOT EXISTS depends (id INTEGER, depend TEXT, comp TEXT, ver TEXT, pkg INTEGER DEFAULT -1)")
-	stmt.Exec()
-	stmt, _ = db.Prepare("CREATE TABLE IF NOT EXISTS optdepends (id INTEGER, optdepend TEXT, pkg INTEGER DEFAULT -1)")
-	stmt.Exec()
-	stmt, _ = db.Prepare("CREATE TABLE IF NOT EXISTS provides (id INTEGER, provide TEXT, comp TEXT, ver TEXT, pkg INTEGER DEFAULT -1)")
-	stmt.Exec()
-	stmt, _ = db.Prepare("CREATE TABLE IF NOT EXISTS conflicts (id INTEGER, conflict TEXT, comp TEXT, ver TEXT, pkg INTEGER DEFAULT -1)")
-	stmt.Exec()
-	stmt, _ = db.Prepare("CREATE TABLE IF NOT EXISTS makedepends (id INTEGER, depend TEXT, comp TEXT, ver TEXT, pkg INTEGER DEFAULT -1)")
-	stmt.Exec()
-	stmt, _ = db.Prepare("CREATE TABLE IF NOT EXISTS licences (id INTEGER, licence TEXT)")
-	stmt.Exec()
-	stmt, _ = db.Prepare("CREATE TABLE IF NOT EXISTS packagers (id INTEGER PRIMARY KEY, packager TEXT UNIQUE)")
-	stmt.Exec()
-	stmt, _ = db.Prepare("CREATE TABLE IF NOT EXISTS repos (id INTEGER PRIMARY KEY, repo TEXT UNIQUE)")
-	stmt.Exec()
+	defer rows.Close()
 
-	fmt.Println("packagers table ...")
-	j := 1
-	for i, pkg := range pkgs {
-		if len(pkg.PACKAGER) < 1 {
-			continue
-		}
-		stmt, _ := db.Prepare("INSERT or IGNORE INTO packagers (id,packager) VALUES (?, ?)")
-		ret, _ := stmt.Exec(j, pkg.PACKAGER)
-		if ret != nil {
-			nb, _ := ret.RowsAffected()
-			if nb > 0 {
-				pkgs[i].PACKAGER = strconv.FormatInt(int64(j), 10)
-				j = j + 1
-			} else {
-				var j int64
-				err = db.QueryRow("SELECT id FROM packagers WHERE packager='" + pkg.PACKAGER + "' LIMIT 1").Scan(&j)
-				if err != nil {
-					log.Fatal(err)
-				}
-				pkgs[i].PACKAGER = strconv.FormatInt(int64(j), 10)
-			}
-		}
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
 	}
-
-	fmt.Println("repos table ...")
-	j = 1
-	for i, pkg := range pkgs {
-		if len(pkg.REPO) < 1 {
-			continue
+	ret := [][]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(cols))
+		pointers := make([]interface{}, len(cols))
+		for i := range values {
+			pointers[i] = &values[i]
 		}
-		stmt, _ := db.Prepare("INSERT or IGNORE INTO repos (id,repo) VALUES (?, ?)")
-		ret, _ := stmt.Exec(j, pkg.REPO)
-		if ret != nil {
-			nb, _ := ret.RowsAffected()
-			if nb > 0 {
-				pkgs[i].REPO = strconv.FormatInt(int64(j), 10)
-				j = j + 1
-			} else {
-				var j int64
-				err = db.QueryRow("SELECT id FROM repos WHERE repo='" + pkg.REPO + "' LIMIT 1").Scan(&j)
-				if err != nil {
-					log.Fatal(err)
-				}
-				pkgs[i].REPO = strconv.FormatInt(int64(j), 10)
-			}
+		if err := rows.Scan(pointers...); err != nil {
+			return cols, ret, err
 		}
-	}
-
-	fmt.Println("main table ...")
-	sqlStr := "INSERT or IGNORE INTO pkgs (id, name, base, version, repo, url, desc, builddate, csize, isize, packager) VALUES "
-	vals := []interface{}{}
-	for i, pkg := range pkgs {
-		sqlStr += "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?),"
-		t := time.Unix(pkg.BUILDDATE, 0)
-		vals = append(vals, pkg.id, pkg.NAME, pkg.getBase(), pkg.VERSION, pkg.REPO, pkg.URL, pkg.DESC, t.Format("2006-02-01 15:04:05"), pkg.CSIZE, pkg.ISIZE, pkg.PACKAGER)
-		if i%50 == 0 {
-			sqlStr = strings.TrimSuffix(sqlStr, ",")
-			stmt, err := db.Prepare(sqlStr)
-			if err != nil {
-				fmt.Println("Error Prepare", sqlStr)
-				log.Fatal(err)
-			}
-			//fmt.Print(i, "... ")
-			_, err = stmt.Exec(vals...)
-			if err != nil {
-				//fmt.Println("Error insert ", i, sqlStr)
-				//fmt.Println("Error insert ", i, vals)
-				log.Fatal(err)
+		for i, value := range values {
+			if b, ok := value.([]byte); ok {
+				values[i] = string(b)
 			}
-			sqlStr = "INSERT or IGNORE INTO pkgs (id, name, base, version, repo, url, desc, builddate, csize, isize, packager) VALUES "
-			vals = []interface{}{}
 		}
+		ret = append(ret, values)
 	}
-	if len(vals) > 0 {
-		sqlStr = strings.TrimSuffix(sqlStr, ",")
-		stmt, err := db.Prepare(sqlStr)
-		if err != nil {
-			fmt.Println("Error insert Prepare", sqlStr)
-			log.Fatal(err)
-		}
-		//fmt.Println("rest ...")
-		stmt.Exec(vals...)
-	}
-
-	fmt.Println("depends table ...")
-	for _, pkg := range pkgs {
-		if len(pkg.DEPENDS) < 1 {
-			continue
-		}
+	return cols, ret, rows.Err()
+}
 
-		sqlStr := "INSERT INTO depends (id, depend, comp, ver, pkg) VALUES "
-		vals := []interface{}{}
-		for _, dep := range pkg.DEPENDS {
-			dep = strings.TrimSpace(dep)
-			ver := ""
-			comp := getSepDepend(dep)
-			if comp != "" {
-				tmp := strings.SplitN(dep, comp, 2)
-				ver = tmp[1]
-				dep = tmp[0]
-			}
-			sqlStr += "(?, ?, ?, ?, ?),"
-			// TODO ? dep = "" if pkgs.FindByName(dep).Valid
-			vals = append(vals, pkg.id, dep, comp, ver, pkgs.FindByName(dep))
-		}
-		sqlStr = strings.TrimSuffix(sqlStr, ",")
-		stmt, _ := db.Prepare(sqlStr)
-		_, err = stmt.Exec(vals...)
-		if err != nil {
-			fmt.Println("Error depends insert", sqlStr, vals)
-			log.Fatal(err)
-		}
+func sqlValueString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05")
 	}
+	return fmt.Sprint(value)
+}
 
-	fmt.Println("optional depends table ...")
-	for _, pkg := range pkgs {
-		if len(pkg.OPTDEPENDS) < 1 {
-			continue
-		}
-
-		sqlStr := "INSERT INTO optdepends (id, optdepend, pkg) VALUES "
-		vals := []interface{}{}
-		for _, dep := range pkg.OPTDEPENDS {
-			dep = strings.SplitN(dep, ":", 2)[0]
-			dep = strings.TrimSpace(dep)
-			comp := getSepDepend(dep)
-			if comp != "" {
-				dep = strings.SplitN(dep, comp, 2)[0]
-			}
-			sqlStr += "(?, ?, ?),"
-			// TODO ? dep = "" if pkgs.FindByName(dep).Valid
-			vals = append(vals, pkg.id, dep, pkgs.FindByName(dep))
-		}
-		sqlStr = strings.TrimSuffix(sqlStr, ",")
-		stmt, _ := db.Prepare(sqlStr)
-		_, err = stmt.Exec(vals...)
-		if err != nil {
-			fmt.Println("Error optional depends insert", sqlStr, vals)
-			log.Fatal(err)
-		}
+func sqlValueJson(value interface{}) []byte {
+	if t, ok := value.(time.Time); ok {
+		value = t.Format("2006-01-02 15:04:05")
 	}
+	buff := &bytes.Buffer{}
+	enc := json.NewEncoder(buff)
+	enc.SetEscapeHTML(false)
+	enc.Encode(value)
+	return bytes.TrimSuffix(buff.Bytes(), []byte("\n"))
+}
 
-	fmt.Println("conflits table ...")
-	for _, pkg := range pkgs {
-		if len(pkg.CONFLICTS) < 1 {
-			continue
-		}
-
-		sqlStr := "INSERT INTO conflicts (id, conflict, comp, ver, pkg) VALUES "
-		vals := []interface{}{}
-		for _, dep := range pkg.CONFLICTS {
-			dep = strings.TrimSpace(dep)
-			ver := ""
-			comp := getSepDepend(dep)
-			if comp != "" {
-				tmp := strings.SplitN(dep, comp, 2)
-				ver = tmp[1]
-				dep = tmp[0]
-			}
-			sqlStr += "(?, ?, ?, ?, ?),"
-			vals = append(vals, pkg.id, dep, comp, ver, pkgs.FindByName(dep))
-		}
-		sqlStr = strings.TrimSuffix(sqlStr, ",")
-		stmt, _ := db.Prepare(sqlStr)
-		_, err = stmt.Exec(vals...)
-		if err != nil {
-			fmt.Println("Error conflicts insert", sqlStr, vals)
-			log.Fatal(err)
-		}
+/*
+ * json object, keys in columns order
+ */
+func sqlRowJson(cols []string, row []interface{}) string {
+	items := make([]string, len(cols))
+	for i, col := range cols {
+		items[i] = string(sqlValueJson(col)) + ":" + string(sqlValueJson(row[i]))
 	}
+	return "{" + strings.Join(items, ",") + "}"
+}
 
-	fmt.Println("provides table ...")
-	for _, pkg := range pkgs {
-		if len(pkg.PROVIDES) < 1 {
-			continue
+func printRows(out io.Writer, format string, cols []string, rows [][]interface{}) error {
+	switch format {
+	case "csv", "tsv":
+		w := csv.NewWriter(out)
+		if format == "tsv" {
+			w.Comma = '\t'
 		}
-
-		sqlStr := "INSERT INTO provides (id, provide, comp, ver, pkg) VALUES "
-		vals := []interface{}{}
-		for _, dep := range pkg.PROVIDES {
-			dep = strings.TrimSpace(dep)
-			ver := ""
-			comp := getSepDepend(dep)
-			if comp != "" {
-				tmp := strings.SplitN(dep, comp, 2)
-				ver = tmp[1]
-				dep = tmp[0]
+		w.Write(cols)
+		for _, row := range rows {
+			record := make([]string, len(row))
+			for i, value := range row {
+				record[i] = sqlValueString(value)
 			}
-			sqlStr += "(?, ?, ?, ?, ?),"
-			vals = append(vals, pkg.id, dep, comp, ver, pkgs.FindByName(dep))
+			w.Write(record)
 		}
-		sqlStr = strings.TrimSuffix(sqlStr, ",")
-		stmt, _ := db.Prepare(sqlStr)
-		_, err = stmt.Exec(vals...)
-		if err != nil {
-			fmt.Println("Error provides insert", sqlStr, vals)
-			log.Fatal(err)
+		w.Flush()
+		return w.Error()
+	case "json":
+		items := make([]string, len(rows))
+		for i, row := range rows {
+			items[i] = "  " + sqlRowJson(cols, row)
 		}
-	}
-
-	fmt.Println("licences table ...")
-	for _, pkg := range pkgs {
-		if len(pkg.LICENSE) < 1 {
-			continue
+		if len(items) < 1 {
+			fmt.Fprintln(out, "[]")
+			return nil
 		}
-
-		sqlStr := "INSERT INTO licences (id, licence) VALUES "
-		vals := []interface{}{}
-		for _, dep := range pkg.LICENSE {
-			sqlStr += "(?, ?),"
-			vals = append(vals, pkg.id, strings.TrimSpace(dep))
+		fmt.Fprintf(out, "[\n%s\n]\n", strings.Join(items, ",\n"))
+	case "ndjson":
+		for _, row := range rows {
+			fmt.Fprintln(out, sqlRowJson(cols, row))
 		}
-		sqlStr = strings.TrimSuffix(sqlStr, ",")
-		stmt, _ := db.Prepare(sqlStr)
-		_, err = stmt.Exec(vals...)
-		if err != nil {
-			fmt.Println("Error licences insert", sqlStr, vals)
-			log.Fatal(err)
+	case "markdown":
+		escape := strings.NewReplacer("|", "\\|", "\n", " ")
+		header := make([]string, len(cols))
+		for i, col := range cols {
+			header[i] = escape.Replace(col)
 		}
-	}
-
-	for _, pkg := range pkgs {
-		if len(pkg.MAKEDEPENDS) < 1 {
-			continue
-		}
-		sqlStr := "INSERT INTO makedepends (id, depend, comp, ver, pkg) VALUES "
-		vals := []interface{}{}
-		for _, dep := range pkg.MAKEDEPENDS {
-			dep = strings.TrimSpace(dep)
-			comp := ""
-			if strings.Contains(dep, ">=") {
-				comp = ">="
-			} else {
-				if strings.Contains(dep, "=") {
-					comp = "="
-				}
-			}
-			ver := ""
-			if len(comp) > 0 {
-				tmp := strings.SplitN(dep, "=", 2)
-				ver = tmp[1]
-				dep = tmp[0]
-				dep = strings.TrimSuffix(dep, ">")
-
+		fmt.Fprintln(out, "| "+strings.Join(header, " | ")+" |")
+		fmt.Fprintln(out, "|"+strings.Repeat(" --- |", len(cols)))
+		for _, row := range rows {
+			record := make([]string, len(row))
+			for i, value := range row {
+				record[i] = escape.Replace(sqlValueString(value))
 			}
-			sqlStr += "(?, ?, ?, ?, ?),"
-			vals = append(vals, pkg.id, dep, comp, ver, pkgs.FindByName(dep))
+			fmt.Fprintln(out, "| "+strings.Join(record, " | ")+" |")
 		}
-		sqlStr = strings.TrimSuffix(sqlStr, ",")
-		stmt, _ := db.Prepare(sqlStr)
-		_, err = stmt.Exec(vals...)
-		if err != nil {
-			fmt.Println("Error makedepends insert", sqlStr)
-			log.Fatal(err)
-		}
-	}
-
-	fmt.Println("create index...")
-	stmt, _ = db.Prepare("CREATE INDEX index_repo ON pkgs (repo ASC);")
-	stmt.Exec()
-	stmt, _ = db.Prepare("CREATE INDEX index_name ON pkgs (name ASC);")
-	stmt.Exec()
-
-	/*
-		// replaced by pkgs.FindByName(dep)
-		fmt.Println("JOIN depends to pkgs...")
-		stmt, _ = db.Prepare("update depends set pkg=(select id from pkgs where depends.depend LIKE pkgs.name LIMIT 1)")
-		_, err = stmt.Exec()
-		if err != nil {
-			fmt.Println("Error depends link depend name to package id", sqlStr)
-			log.Fatal(err)
+	default:
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		lines := make([]string, len(cols))
+		for i, col := range cols {
+			lines[i] = strings.Repeat("-", len(col))
 		}
-		fmt.Println("JOIN makedepends to pkgs...")
-		stmt, _ = db.Prepare("update makedepends set pkg=(select id from pkgs where makedepends.depend LIKE pkgs.name LIMIT 1)")
-		_, err = stmt.Exec()
-		if err != nil {
-			fmt.Println("Error makedepends link depend name to package id", sqlStr)
-			log.Fatal(err)
+		fmt.Fprintln(w, strings.Join(cols, "\t"))
+		fmt.Fprintln(w, strings.Join(lines, "\t"))
+		for _, row := range rows {
+			record := make([]string, len(row))
+			for i, value := range row {
+				record[i] = strings.ReplaceAll(sqlValueString(value), "\n", " ")
+			}
+			fmt.Fprintln(w, strings.Join(record, "\t"))
 		}
-	*/
-
-	telapsed := time.Since(tstart)
-	fmt.Println("\nsql duration: ", COLOR_GREEN, telapsed, COLOR_NONE, "\n ")
-
-	fmt.Println("\n---\nTests...")
-	var nb int64
-	// SELECT count(DISTINCT id)
-	err = db.QueryRow("SELECT count(id) AS nb FROM pkgs").Scan(&nb)
-	if err != nil {
-		log.Fatal(err)
-	}
-	fmt.Println(nb, " pkgs in Database sql ")
-	/*
-		select name,builddate from pkgs where strftime('%Y',builddate)='2013'
-
-		SELECT pkgs.id, name, depend  FROM pkgs LEFT JOIN depends ON pkgs.id=depends.id WHERE depend='gtk2' order by name
-		SELECT pkgs.id, name, depend  FROM pkgs LEFT JOIN makedepends ON pkgs.id=makedepends.id WHERE depend='gtk2' order by name
-
-		SELECT *  FROM packagers WHERE packager LIKE '%manjaro%' order by packager
-		SELECT name, packagers.packager FROM pkgs LEFT JOIN packagers ON pkgs.packager=packagers.id WHERE packagers.packager LIKE '%manjaro%' order by packagers.packager
-
-		SELECT count(name) as "count", packagers.packager, packagers.id FROM pkgs LEFT JOIN packagers ON pkgs.packager=packagers.id GROUP BY packagers.id HAVING packagers.packager LIKE '%manjaro%' order by "count" DESC
-	*/
-
-	dir, _ := os.Getwd()
-	err = moveFile("/tmp/pacman.db", dir+"/pacman.db")
-	if err != nil {
-		log.Fatal(err)
+		return w.Flush()
 	}
+	return nil
 }
 
 /*
-./ls-alpm -q "select * from repos;"
-OR
-sqlite3 pacman.db "select * from repos;"
+./alpm-db query "select * from repos"
+./alpm-db query --format json "SELECT name, version FROM pkgs WHERE name LIKE 'pac%'"
+title is displayed only with table format
 */
-func RequestSql(requestStr string) {
-	if len(requestStr) < 5 {
-		return
-	}
-	db, err := sql.Open("sqlite3", "pacman.db")
+func RunSql(title string, requestStr string, format string) bool {
+	db, err := openPacmanDb()
 	if err != nil {
-		log.Fatal(err)
+		fmt.Fprintln(os.Stderr, err)
+		return false
 	}
 	defer db.Close()
 
-	fmt.Println("::", COLOR_BLUE, requestStr, COLOR_NONE)
-	rows, err := db.Query(requestStr)
+	cols, rows, err := queryRows(db, requestStr)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return false
 	}
-	cols, _ := rows.Columns()
-	fmt.Println("--------------------" + COLOR_GREEN)
-	fmt.Printf(strings.Join(cols, "\t"+COLOR_NONE+"|\t"+COLOR_GREEN))
-	fmt.Println(COLOR_NONE, "\n--------------------")
-	defer rows.Close()
-
-	for rows.Next() {
-		columns := make([]string, len(cols))
-		columnPointers := make([]interface{}, len(cols))
-		for i, _ := range columns {
-			columnPointers[i] = &columns[i]
-		}
-
-		err := rows.Scan(columnPointers...)
-		if err != nil {
-			fmt.Println("Error: ", err)
+	if format == "table" {
+		if len(title) < 1 {
+			title = requestStr
 		}
-		fmt.Printf(strings.Join(columns, "\t|\t") + "\n")
+		fmt.Println("::", COLOR_GREEN, title, COLOR_NONE)
+	}
+	if err := printRows(os.Stdout, format, cols, rows); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return false
 	}
-	fmt.Println("")
+	if format == "table" {
+		fmt.Println("")
+	}
+	return true
 	/*
 		./ls-alpm -q "select * from repos"
 		./ls-alpm -q "SELECT count(name) as "count", packagers.packager, packagers.id FROM pkgs LEFT JOIN packagers ON pkgs.packager=packagers.id GROUP BY packagers.id HAVING packagers.packager LIKE '%manjaro%' order by 'count' DESC"
 	*/
 }
 
-func RunSql(title string, requestStr string) {
-	var out bytes.Buffer
-	var err bytes.Buffer
+func SqlTableStruct(tableName string, format string) bool {
+	return RunSql(tableName, "pragma table_info('"+tableName+"');", format)
+}
 
-	cmd := exec.Command("sqlite3", "-readonly", "pacman.db", ""+requestStr+";")
-	if !strings.Contains(requestStr, "json_object") {
-		// NOT ./ls-alpm -q "select json_object('id',id,'repo',repo) from repos"
-		cmd = exec.Command("sqlite3", "-readonly", "pacman.db", ""+requestStr+";", "-cmd", ".header on", "-cmd", ".mode column")
-		if len(title) < 1 {
-			title = requestStr
+func validSqlFormat(format string) bool {
+	for _, f := range sqlFormats {
+		if f == format {
+			return true
 		}
-		fmt.Println("::", COLOR_GREEN, title, COLOR_NONE)
 	}
-	cmd.Stdout = &out
-	cmd.Stderr = &err
-	cmd.Run()
-	if strings.Contains(requestStr, "json_object") {
-		fmt.Printf("[\n%v\n]\n", strings.TrimSuffix(strings.ReplaceAll(out.String(), "}\n", "},\n"), ",\n"))
-	} else {
-		fmt.Println(out.String())
+	return false
+}
+
+/*
+ * ./alpm-db query "SELECT * FROM pkgs"
+ */
+func cmdQuery(fs *flag.FlagSet, args []string) int {
+	format := fs.String("format", "table", "output: "+strings.Join(sqlFormats, ", "))
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
 	}
-	if len(err.String()) > 2 {
-		fmt.Println(err.String())
-		os.Exit(9)
+	if len(positional) != 1 || !validSqlFormat(*format) {
+		fs.Usage()
+		return EXIT_USAGE
 	}
+	if !RunSql("", positional[0], *format) {
+		return EXIT_FAILURE
+	}
+	return EXIT_OK
 }
 
-func SqlTableStruct(tableName string) {
-	var out bytes.Buffer
-	cmd := exec.Command("sqlite3", "pacman.db", "pragma table_info('"+tableName+"');", "-cmd", ".header on", "-cmd", ".mode column")
-	cmd.Stdout = &out
-	cmd.Run()
-	fmt.Println("::", COLOR_GREEN, tableName, COLOR_NONE)
-	fmt.Println(out.String())
+/*
+ * ./alpm-db info
+ */
+func cmdInfo(fs *flag.FlagSet, args []string) int {
+	format := fs.String("format", "table", "output: table, markdown")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(positional) > 0 || (*format != "table" && *format != "markdown") {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	for _, table := range []string{"pkgs", "depends", "makedepends", "packagers", "repos"} {
+		if *format == "markdown" {
+			fmt.Println("\n###", table)
+		}
+		if !SqlTableStruct(table, *format) {
+			return EXIT_FAILURE
+		}
+	}
+
+	if *format == "markdown" {
+		fmt.Println("\n### Mainteners")
+	}
+	if !RunSql("Mainteners", "SELECT count(name) as 'packages', packagers.packager, packagers.id FROM pkgs LEFT JOIN packagers ON pkgs.packager=packagers.id GROUP BY packagers.id HAVING packagers.packager LIKE '%manjaro%' order by packages DESC", *format) {
+		return EXIT_FAILURE
+	}
+	return EXIT_OK
 }
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"alpm-db/alpmdb"
+)
+
+/*
+ * repos: default or from pacman.conf
+ * with local pacman db, pacman.conf is used if exists
+ * out: progress messages
+ */
+func loadRepos(out io.Writer, config *optionalValue, local bool) ([]string, *alpmdb.PacmanConf, error) {
+	if !config.set && !local {
+		return defaultRepos, nil, nil
+	}
+	confFile := config.value
+	if confFile == "" {
+		confFile = alpmdb.PacmanConfFile
+	}
+	conf, err := alpmdb.ParsePacmanConf(confFile)
+	if err != nil {
+		if config.set {
+			return nil, nil, err
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return defaultRepos, nil, nil
+	}
+	repos := conf.RepoNames()
+	fmt.Fprintln(out, "::", confFile, conf.Architecture, repos)
+	return repos, conf, nil
+}
+
+/*
+ * pacman.db was generated for this architecture ?
+ * pacman.db of previous version (without repos.arch, or other arch in ./pacman.db) is regenerated
+ */
+func isSameArch(dbFile string, arch string) bool {
+	db, err := alpmdb.OpenReadOnly(dbFile)
+	if err != nil {
+		return false
+	}
+	defer db.Close()
+	repos, err := alpmdb.Repos(db)
+	if err != nil {
+		return false
+	}
+	for _, repo := range repos {
+		if repo.ARCH != arch {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+ * ./alpm-db sync [-b testing] [-m url]... [--arch aarch64] [--files] [--verify]
+ * download repos and generate pacman.db
+ */
+func cmdSync(fs *flag.FlagSet, args []string) int {
+	var mirrors listValue
+	var config optionalValue
+	branch := fs.String("b", "stable", "branch")
+	fs.Var(&mirrors, "m", "mirror url, can be repeated: next mirror if download fails (\"local\": use "+SyncDb+")\nnot with --config or --mirrorlist")
+	fs.Var(&config, "config", "repos and servers from pacman.conf (--config="+alpmdb.PacmanConfFile+")\nwith -m local: repos from pacman.conf")
+	mirrorlist := fs.String("mirrorlist", "", "mirrors from a pacman mirrorlist `file` (Server = .../$repo/$arch), not with --config")
+	archLayout := fs.Bool("archlinux", false, "use archlinux mirror format ($repo/os/$arch, $arch/$repo if not "+alpmdb.DefaultArch+")")
+	arch := fs.String("arch", "", "architecture: aarch64, i686... (default "+alpmdb.DefaultArch+" or Architecture of pacman.conf)")
+	withFiles := fs.Bool("files", false, "use .files db (packages files in pacman.db)")
+	withInstalled := fs.Bool("installed", false, "add installed packages ("+LocalDb+") in pacman.db")
+	verify := fs.Bool("verify", false, "verify dbs signatures (Required, or SigLevel with --config)")
+	keyringFile := fs.String("keyring", alpmdb.PacmanKeyring, "keyring `file` for --verify")
+	force := fs.Bool("force", false, "regenerate ./pacman.db if repos are not modified")
+	withSql := fs.Bool("sql", true, "create sqlite3 ./pacman.db (./pacman-<arch>.db if not "+alpmdb.DefaultArch+")")
+	update := fs.Bool("update", false, "update ./pacman.db: only changed packages, changes in table changes")
+	withJson := fs.Bool("json", false, "create ./pacman.json")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(positional) > 0 {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+
+	local := len(mirrors) == 1 && mirrors[0] == "local"
+	// only one source of servers, pacman.conf with -m local is only for repos
+	sources := 0
+	for _, used := range []bool{len(mirrors) > 0, *mirrorlist != "", config.set && !local} {
+		if used {
+			sources++
+		}
+	}
+	if sources > 1 {
+		fmt.Fprintln(os.Stderr, "alpm-db sync: -m, --mirrorlist and --config can not be used together")
+		return EXIT_USAGE
+	}
+
+	LocalRepos := SyncDb
+	ext := ".db"
+	if *withFiles {
+		// .files db: same as .db with packages files
+		ext = ".files"
+	}
+
+	repos, conf, err := loadRepos(os.Stdout, &config, local)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+
+	if *arch == "" {
+		*arch = alpmdb.DefaultArch
+		if conf != nil {
+			*arch = conf.Architecture
+		}
+	} else if conf != nil {
+		conf.Architecture = *arch
+	}
+
+	dbFile := pacmanDbFile(*arch)
+
+	infos := make(map[string]*alpmdb.RepoInfo, len(repos))
+	for _, repo := range repos {
+		infos[repo] = &alpmdb.RepoInfo{NAME: repo, SIGSTATUS: alpmdb.SIG_DISABLED}
+	}
+
+	if !local {
+		LocalRepos = localReposDir(*arch)
+		os.MkdirAll(LocalRepos, os.ModeDir|0777)
+		servers := alpmdb.MirrorServers(mirrors, *branch, repos, *archLayout, *arch)
+		if conf != nil {
+			servers = conf.Servers()
+		} else if *mirrorlist != "" {
+			if servers, err = alpmdb.ParseMirrorlist(*mirrorlist, repos, *arch); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return EXIT_FAILURE
+			}
+		} else if len(servers) < 1 {
+			servers = alpmdb.MirrorServers([]string{url_mirror}, *branch, repos, *archLayout, *arch)
+		}
+		var changed bool
+		changed, infos = downloadRepos(os.Stdout, servers, repos, ext, LocalRepos, *verify)
+		// db of a previous sync is not used: pacman.db and history would be stale
+		if missing := notDownloaded(infos, repos); len(missing) > 0 {
+			fmt.Fprintln(os.Stderr, "alpm-db sync: repos not downloaded:", strings.Join(missing, " "))
+			return EXIT_FAILURE
+		}
+		if conf == nil && *mirrorlist == "" && !*archLayout {
+			// manjaro layout: $branch/$repo/$arch
+			for _, info := range infos {
+				info.BRANCH = alpmdb.ManjaroBranch(*branch, *arch)
+			}
+		}
+		if !changed && !*force && *withSql && !*withJson && isUpToDate(dbFile, repos, ext, LocalRepos) && isSameArch(dbFile, *arch) {
+			fmt.Println("repos not modified,", dbFile, "is up to date (--force to regenerate)")
+			return EXIT_OK
+		}
+	}
+
+	rejected := false
+	if *verify {
+		keyring, err := alpmdb.LoadKeyring(*keyringFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "keyring:", err)
+			return EXIT_FAILURE
+		}
+		levels := make(map[string]string, len(repos))
+		for _, repo := range repos {
+			levels[repo] = alpmdb.SIGLEVEL_REQUIRED
+		}
+		if conf != nil {
+			levels = conf.DbSigLevels()
+		}
+		nb := len(repos)
+		repos = verifyRepos(infos, repos, ext, LocalRepos, levels, keyring)
+		rejected = len(repos) != nb
+	}
+
+	for _, info := range infos {
+		info.ARCH = *arch
+	}
+
+	pkgs, err := parseRepos(os.Stdout, repos, ext, LocalRepos, alpmdb.PackageFilter{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	pkgs, ignored := pkgs.FilterArch(*arch)
+	if ignored > 0 {
+		fmt.Println("=>", ignored, "packages ignored: not", *arch, "or any")
+	}
+
+	if *withJson {
+		genJson(pkgs)
+	}
+	var installed alpmdb.Packages
+	if *withInstalled {
+		if installed, err = alpmdb.LoadLocalDb(LocalDb); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return EXIT_FAILURE
+		}
+		fmt.Println("=>", len(installed), "installed packages")
+	}
+
+	if *withSql && !alpmdb.Fts5Available() {
+		fmt.Fprintln(os.Stderr, COLOR_RED+"warning:"+COLOR_NONE, "sqlite3 built without fts5, search index is fts4: go build -tags sqlite_fts5 (make)")
+	}
+	if *withSql && *update {
+		fmt.Println("\n", COLOR_BLUE, "--- sqlite update...", COLOR_NONE)
+		changes, err := alpmdb.UpdateSqlite(dbFile, pkgs, installed, infos)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return EXIT_FAILURE
+		}
+		if len(changes) > 0 {
+			fmt.Println("")
+			printDiff(changes, "OLD", "NEW")
+		}
+	} else if *withSql {
+		fmt.Println("\n", COLOR_BLUE, "--- sqlite génération...", COLOR_NONE)
+		if err := alpmdb.GenSqlite(dbFile, pkgs, installed, infos); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return EXIT_FAILURE
+		}
+	}
+
+	if rejected {
+		return EXIT_FAILURE
+	}
+	return EXIT_OK
+}
+
+/*
+ * ./alpm-db export [packages...]
+ * json of packages in downloaded repos
+ */
+func cmdExport(fs *flag.FlagSet, args []string) int {
+	var config optionalValue
+	local := fs.Bool("local", false, "use pacman sync dbs ("+SyncDb+")")
+	fs.Var(&config, "config", "repos from pacman.conf (--config="+alpmdb.PacmanConfFile+")")
+	withFiles := fs.Bool("files", false, "use .files db (with packages files)")
+	arch := fs.String("arch", alpmdb.DefaultArch, "architecture of downloaded repos")
+	output := fs.String("o", "", "output `file` (default stdout)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+
+	filters := alpmdb.PackageFilter{}
+	for _, name := range positional {
+		filters[name] = true
+	}
+	ext := ".db"
+	if *withFiles {
+		ext = ".files"
+	}
+	localRepos := localReposDir(*arch)
+	if *local {
+		localRepos = SyncDb
+	}
+
+	var progress io.Writer = os.Stdout
+	if *output == "" {
+		// keep stdout only for json
+		progress = os.Stderr
+	}
+	repos, _, err := loadRepos(progress, &config, *local)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	pkgs, err := parseRepos(progress, repos, ext, localRepos, filters)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	if len(pkgs) < 1 {
+		return EXIT_FAILURE
+	}
+
+	if *output == "" {
+		fmt.Print(datasToJson(pkgs))
+		return EXIT_OK
+	}
+	if err := os.WriteFile(*output, []byte(datasToJson(pkgs)), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	fmt.Println("=>", *output)
+	return EXIT_OK
+}
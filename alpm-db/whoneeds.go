@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"alpm-db/alpmdb"
+)
+
+func printNeeders(n *alpmdb.Needer, prefix string) {
+	for i, child := range n.REQUIREDBY {
+		branch, indent := "├─ ", "│  "
+		if i == len(n.REQUIREDBY)-1 {
+			branch, indent = "└─ ", "   "
+		}
+		typ := ""
+		if child.TYPE != "depends" {
+			typ = " [" + strings.TrimSuffix(child.TYPE, "depends") + "]"
+		}
+		fmt.Println(prefix+branch+child.NAME, COLOR_GRAY+child.VERSION, child.REPO+COLOR_NONE+typ)
+		printNeeders(child, prefix+indent)
+	}
+}
+
+func runWhoNeeds(name string, depth int, withMake bool, withOpt bool, asJson bool) bool {
+	db, err := openPacmanDb()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	defer db.Close()
+
+	root, nb, err := alpmdb.WhoNeeds(db, name, depth, withMake, withOpt)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	if root == nil {
+		fmt.Fprintln(os.Stderr, "package", name, "not found in pacman.db")
+		return false
+	}
+	if asJson {
+		printJson(root)
+		return true
+	}
+	fmt.Println(COLOR_GREEN+root.NAME, root.VERSION, root.REPO+COLOR_NONE)
+	printNeeders(root, "")
+	fmt.Println("\n=>", nb, "packages")
+	return true
+}
+
+/*
+ * ./alpm-db whoneeds glibc [--depth N] [--make] [--opt]
+ */
+func cmdWhoNeeds(fs *flag.FlagSet, args []string) int {
+	depth := fs.Int("depth", 0, "max levels (0: no limit)")
+	withMake := fs.Bool("make", false, "with makedepends")
+	withOpt := fs.Bool("opt", false, "with optdepends")
+	asJson := fs.Bool("json", false, "json output")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	if !runWhoNeeds(positional[0], *depth, *withMake, *withOpt, *asJson) {
+		return EXIT_FAILURE
+	}
+	return EXIT_OK
+}